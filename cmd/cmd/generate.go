@@ -13,12 +13,40 @@ import (
 
 var dir string
 var extra bool
+var resource bool
+var global bool
+var controller string
 
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
-	Use:   "generate [item] [name]",
-	Short: "Generate a new item: model, controller",
-	Args:  cobra.ExactArgs(2),
+	Use:   "generate [item] [name] [args...]",
+	Short: "Generate a new item: model, controller, request, scaffold, middleware",
+	Long: `Generate a new item: model, controller, request, scaffold, middleware
+
+Models take field specs as args, like:
+  go-bat generate model Post title:string:required body:text published_at:time? author_id:ref:users
+
+The types are string, text, int, bigint, float, bool, time and ref:<table>, a type ending in ? is nullable. The
+modifiers required, unique and index can be added after the type.
+
+Controllers take action names as args, every action gets a GET route and a handler. With --resource the controller
+also gets the index, show, create, store, edit, update and destroy routes, like:
+  go-bat generate controller Posts --resource
+With an Inertia extra the handlers render pages, which are generated in frontend/src/Pages.
+
+Requests take field specs with the types of models and the validation rules of bat.Validator, like:
+  go-bat generate request CreateUser name:string:required:max=255 email:string:required:email:unique=users
+The rules are required, email, min=<n>, max=<n> and unique=<table>[.<column>].
+
+Scaffolds take the field specs of models and generate the model with its store and tests, a request, a resource
+controller with tests and, with an Inertia extra, the pages of the resource. The controller is registered in
+cmd/serve.go, like:
+  go-bat generate scaffold Post title:string:required body:text
+
+Middleware gets a test and is registered for all routes in cmd/serve.go with --global, or for the routes of a
+controller with --controller, like:
+  go-bat generate middleware Audit --controller Posts`,
+	Args: cobra.MinimumNArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		logger := pkg.NewLogger(pkg.LoggerOutputTypeHuman, &slog.HandlerOptions{Level: slog.LevelDebug}, false)
 
@@ -33,7 +61,12 @@ var generateCmd = &cobra.Command{
 		}
 
 		logger.Info("Generating", "item", args[0], "name", args[1])
-		err = gen.Generate(args[1], extra)
+		err = gen.Generate(args[1], args[2:], internal.GenerateOptions{
+			Extra:      extra,
+			Resource:   resource,
+			Global:     global,
+			Controller: controller,
+		})
 		if err != nil {
 			return err
 		}
@@ -56,5 +89,8 @@ func init() {
 	// is called directly, e.g.:
 	// generateCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	generateCmd.Flags().StringVar(&dir, "dir", "", "The directory of the project, defaults to \".\"")
-	generateCmd.Flags().BoolVar(&extra, "extra", false, "Generate extra files for the model like tests etc.")
+	generateCmd.Flags().BoolVar(&extra, "extra", false, "Generate extra files like tests etc.")
+	generateCmd.Flags().BoolVar(&resource, "resource", false, "Generate the resource routes of a controller")
+	generateCmd.Flags().BoolVar(&global, "global", false, "Register the middleware for all routes")
+	generateCmd.Flags().StringVar(&controller, "controller", "", "Register the middleware for the routes of the controller")
 }
@@ -23,7 +23,7 @@ var noInstallDeps bool
 
 func init() {
 	rootCmd.AddCommand(newCmd)
-	newCmd.Flags().StringArrayVar(&extras, "extra", []string{}, "Add extra features to the project choice of: inertia-react, inertia-svelte, database-pgsql, frontend-auth")
+	newCmd.Flags().StringArrayVar(&extras, "extra", []string{}, "Add extra features to the project choice of: inertia-react, inertia-svelte, inertia-vue, database-pgsql, database-sqlite, database-mysql, frontend-auth, valkey, jobs")
 	newCmd.Flags().StringVar(&packageName, "package-name", "", "The name of the package, defaults to the project name")
 	newCmd.Flags().BoolVar(&force, "force", false, "Force the creation of the project even if the directory is not empty")
 	newCmd.Flags().BoolVar(&noGit, "no-git", false, "Do not create a git repository")
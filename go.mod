@@ -6,15 +6,18 @@ require (
 	github.com/JensvandeWiel/valkeystore v1.0.0
 	github.com/gertd/go-pluralize v0.2.1
 	github.com/gorilla/sessions v1.4.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/iancoleman/strcase v0.3.0
 	github.com/jmoiron/sqlx v1.4.0
 	github.com/labstack/echo-contrib v0.17.2
 	github.com/labstack/echo/v4 v4.13.3
 	github.com/phsym/console-slog v0.3.1
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/romsar/gonertia/v2 v2.0.3
 	github.com/samber/slog-echo v1.15.1
 	github.com/spf13/cobra v1.8.1
 	github.com/valkey-io/valkey-go v1.0.54
+	golang.org/x/sync v0.11.0
 )
 
 require (
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/JensvandeWiel/go-bat/internal/templates/generators"
+	"github.com/gertd/go-pluralize"
+	"github.com/iancoleman/strcase"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var actionNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// InertiaExtra is an extra that provides the Inertia frontend of the project
+type InertiaExtra interface {
+	Extra
+	// PageExtension returns the file extension of the pages
+	PageExtension() string
+	// PageTemplate returns the template of a generated page
+	PageTemplate() string
+	// ScaffoldPagesDir returns the directory of the scaffold page templates in generators.ScaffoldPages
+	ScaffoldPagesDir() string
+}
+
+// inertiaExtra returns the Inertia extra of the project, or nil when the project has no Inertia frontend
+func (p *Project) inertiaExtra() InertiaExtra {
+	for _, extra := range ParseExtras(p.ExtraTypes) {
+		if inertiaExtra, ok := extra.(InertiaExtra); ok {
+			return inertiaExtra
+		}
+	}
+	return nil
+}
+
+// ControllerAction is a handler of a generated controller
+type ControllerAction struct {
+	// Name is the name of the handler method
+	Name   string
+	Method string
+	Path   string
+	// Page is the Inertia page the handler renders, writes redirect instead
+	Page string
+	// Params are the path parameters of the route
+	Params []string
+	// Status is the status the handler responds with
+	Status int
+}
+
+// HTTPMethod returns the net/http constant of the method
+func (a ControllerAction) HTTPMethod() string {
+	return "http.Method" + strings.ToUpper(a.Method[:1]) + strings.ToLower(a.Method[1:])
+}
+
+// HTTPStatus returns the net/http constant of the status
+func (a ControllerAction) HTTPStatus() string {
+	switch a.Status {
+	case http.StatusCreated:
+		return "http.StatusCreated"
+	case http.StatusNoContent:
+		return "http.StatusNoContent"
+	case http.StatusSeeOther:
+		return "http.StatusSeeOther"
+	default:
+		return "http.StatusOK"
+	}
+}
+
+// IsWrite returns whether the handler changes data
+func (a ControllerAction) IsWrite() bool {
+	return a.Method != http.MethodGet
+}
+
+type ControllerGenerator struct {
+	project *Project
+}
+
+// controllerActions returns the actions of the controller, the resource actions first and then a GET action for every
+// action name in args. A controller without actions gets an Index action.
+func controllerActions(name, basePath string, args []string, resource, inertia bool) ([]ControllerAction, error) {
+	var actions []ControllerAction
+	if resource {
+		writeStatus := func(status int) int {
+			if inertia {
+				// Inertia follows the redirect back to a page
+				return http.StatusSeeOther
+			}
+			return status
+		}
+		actions = append(actions,
+			ControllerAction{Name: "Index", Method: http.MethodGet, Path: basePath, Page: name + "/Index", Status: http.StatusOK},
+			ControllerAction{Name: "Create", Method: http.MethodGet, Path: basePath + "/create", Page: name + "/Create", Status: http.StatusOK},
+			ControllerAction{Name: "Store", Method: http.MethodPost, Path: basePath, Status: writeStatus(http.StatusCreated)},
+			ControllerAction{Name: "Show", Method: http.MethodGet, Path: basePath + "/:id", Page: name + "/Show", Params: []string{"id"}, Status: http.StatusOK},
+			ControllerAction{Name: "Edit", Method: http.MethodGet, Path: basePath + "/:id/edit", Page: name + "/Edit", Params: []string{"id"}, Status: http.StatusOK},
+			ControllerAction{Name: "Update", Method: http.MethodPut, Path: basePath + "/:id", Params: []string{"id"}, Status: writeStatus(http.StatusNoContent)},
+			ControllerAction{Name: "Destroy", Method: http.MethodDelete, Path: basePath + "/:id", Params: []string{"id"}, Status: writeStatus(http.StatusNoContent)},
+		)
+	}
+
+	for _, arg := range args {
+		if !actionNameRegex.MatchString(arg) {
+			return nil, fmt.Errorf("invalid action name %q, use lowercase letters, digits and underscores", arg)
+		}
+		action := ControllerAction{
+			Name:   strcase.ToCamel(arg),
+			Method: http.MethodGet,
+			Path:   basePath + "/" + strcase.ToKebab(arg),
+			Page:   name + "/" + strcase.ToCamel(arg),
+			Status: http.StatusOK,
+		}
+		for _, existing := range actions {
+			if existing.Name == action.Name {
+				return nil, fmt.Errorf("action %s is defined twice", action.Name)
+			}
+		}
+		actions = append(actions, action)
+	}
+	if len(actions) == 0 {
+		actions = append(actions, ControllerAction{Name: "Index", Method: http.MethodGet, Path: basePath, Page: name + "/Index", Status: http.StatusOK})
+	}
+	return actions, nil
+}
+
+func (c *ControllerGenerator) Generate(name string, args []string, opts GenerateOptions) error {
+	name = strcase.ToCamel(strings.TrimSuffix(strcase.ToCamel(name), "Controller"))
+	if name == "" {
+		return fmt.Errorf("invalid controller name")
+	}
+	controllerName := name + "Controller"
+	basePath := "/" + pluralize.NewClient().Plural(strcase.ToKebab(name))
+
+	controllerFile := path.Join("controllers", strcase.ToSnake(name)+"_controller.go")
+	if _, err := os.Stat(path.Join(c.project.tempDir, controllerFile)); err == nil {
+		c.project.logger.Error("controller file already exists", "controllerFile", controllerFile)
+		return fmt.Errorf("controller file already exists: %s", controllerFile)
+	}
+
+	inertiaExtra := c.project.inertiaExtra()
+	actions, err := controllerActions(name, basePath, args, opts.Resource, inertiaExtra != nil)
+	if err != nil {
+		c.project.logger.Error("invalid actions", "error", err)
+		return err
+	}
+
+	// Handlers that render pages do not need net/http
+	importHTTP := false
+	for _, action := range actions {
+		importHTTP = importHTTP || inertiaExtra == nil || action.Page == ""
+	}
+
+	data := map[string]interface{}{
+		"PackageName":    c.project.PackageName,
+		"name":           name,
+		"controllerName": controllerName,
+		"basePath":       basePath,
+		"actions":        actions,
+		"inertia":        inertiaExtra != nil,
+		"importHTTP":     importHTTP,
+	}
+
+	err = c.project.writeStringTemplateToFile(controllerFile, generators.ControllerTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	if inertiaExtra != nil {
+		for _, action := range actions {
+			if action.Page == "" {
+				continue
+			}
+			pageFile := filepath.Join("frontend", "src", "Pages", action.Page+inertiaExtra.PageExtension())
+			// Pages are often shared between controllers or written by hand, so they are never overwritten
+			if _, err := os.Stat(path.Join(c.project.tempDir, pageFile)); err == nil {
+				c.project.logger.Info("Page already exists, skipping it", "pageFile", pageFile)
+				continue
+			}
+
+			err = os.MkdirAll(filepath.Dir(path.Join(c.project.tempDir, pageFile)), 0755)
+			if err != nil {
+				return err
+			}
+			err = c.project.writeStringTemplateToFile(pageFile, inertiaExtra.PageTemplate(), action)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.Extra {
+		c.project.logger.Info("Generating extra files for controller")
+		err = c.project.writeStringTemplateToFile(path.Join("controllers", strcase.ToSnake(name)+"_controller_test.go"), generators.ControllerTestTemplate, data)
+		if err != nil {
+			return err
+		}
+
+		c.project.logger.Info("Generated extra files for controller")
+	}
+
+	c.project.logger.Info("Generated controller, register it in cmd/serve.go", "controllerFile", controllerFile, "controller", controllerName)
+	return nil
+}
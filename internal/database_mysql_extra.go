@@ -0,0 +1,119 @@
+package internal
+
+import "github.com/JensvandeWiel/go-bat/internal/templates/database_mysql_extra"
+
+type DatabaseMySQLExtra struct {
+}
+
+func NewDatabaseMySQL() *DatabaseMySQLExtra {
+	return &DatabaseMySQLExtra{}
+}
+
+func (i *DatabaseMySQLExtra) Generate(project *Project) error {
+	err := project.writeStringTemplateToFile("database/connect.go", database_mysql_extra.DatabaseConnectTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("database/migrations/migrations.go", database_mysql_extra.DatabaseMigrationsTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("database/migrations/20250210123935_placeholder.sql", database_mysql_extra.DatabaseMigrationsPlaceholderTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("cmd/migrate.go", database_mysql_extra.MigrateTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.createDirectories([]string{"database/models", "test_helpers"})
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("test_helpers/setup_db.go", database_mysql_extra.TestHelpersSetupDbTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (i *DatabaseMySQLExtra) ModEntries() []string {
+	return []string{
+		"github.com/jmoiron/sqlx v1.4.0",
+		"github.com/go-sql-driver/mysql v1.8.1",
+		"github.com/pressly/goose/v3 v3.24.1",
+		"github.com/testcontainers/testcontainers-go/modules/mariadb v0.35.0",
+		"github.com/testcontainers/testcontainers-go v0.35.0",
+	}
+}
+
+func (i *DatabaseMySQLExtra) GitIgnoreEntries() []string {
+	return []string{}
+}
+
+func (i *DatabaseMySQLExtra) GetExtraPersistentFlags() []string {
+	return []string{
+		"rootCmd.PersistentFlags().String(\"DBHost\", \"localhost\", \"the database host\")",
+		"rootCmd.PersistentFlags().String(\"DBPort\", \"3306\", \"the database port\")",
+		"rootCmd.PersistentFlags().String(\"DBUser\", \"user\", \"the database user\")",
+		"rootCmd.PersistentFlags().String(\"DBPass\", \"password\", \"the database password\")",
+		"rootCmd.PersistentFlags().String(\"DBName\", \"database\", \"the database name\")",
+		"rootCmd.PersistentFlags().StringSlice(\"DBReplicaHosts\", nil, \"the hosts of the database read replicas\")",
+		"rootCmd.PersistentFlags().Int(\"DBMaxOpenConns\", bat.DefaultDatabaseMaxOpenConns, \"the maximum number of open database connections\")",
+		"rootCmd.PersistentFlags().Int(\"DBMaxIdleConns\", bat.DefaultDatabaseMaxIdleConns, \"the maximum number of idle database connections\")",
+		"rootCmd.PersistentFlags().Duration(\"DBConnMaxLifetime\", bat.DefaultDatabaseConnMaxLifetime, \"how long a database connection may be reused\")",
+		"viper.BindPFlag(\"DB_HOST\", rootCmd.PersistentFlags().Lookup(\"DBHost\"))",
+		"viper.BindPFlag(\"DB_PORT\", rootCmd.PersistentFlags().Lookup(\"DBPort\"))",
+		"viper.BindPFlag(\"DB_USER\", rootCmd.PersistentFlags().Lookup(\"DBUser\"))",
+		"viper.BindPFlag(\"DB_PASS\", rootCmd.PersistentFlags().Lookup(\"DBPass\"))",
+		"viper.BindPFlag(\"DB_NAME\", rootCmd.PersistentFlags().Lookup(\"DBName\"))",
+		"viper.BindPFlag(\"DB_REPLICA_HOSTS\", rootCmd.PersistentFlags().Lookup(\"DBReplicaHosts\"))",
+		"viper.BindPFlag(\"DB_MAX_OPEN_CONNS\", rootCmd.PersistentFlags().Lookup(\"DBMaxOpenConns\"))",
+		"viper.BindPFlag(\"DB_MAX_IDLE_CONNS\", rootCmd.PersistentFlags().Lookup(\"DBMaxIdleConns\"))",
+		"viper.BindPFlag(\"DB_CONN_MAX_LIFETIME\", rootCmd.PersistentFlags().Lookup(\"DBConnMaxLifetime\"))",
+	}
+}
+
+func (i *DatabaseMySQLExtra) ExtraType() ExtraType {
+	return DatabaseMySQL
+}
+
+func (i *DatabaseMySQLExtra) Dialect() Dialect {
+	return mysqlDialect{}
+}
+
+func (i *DatabaseMySQLExtra) DisallowedExtraTypes() []ExtraType {
+	return []ExtraType{DatabasePgSQL, DatabaseSQLite}
+}
+
+func (i *DatabaseMySQLExtra) ComposerServices() []string {
+	return []string{`  mariadb:
+    image: mariadb:11
+    environment:
+      MARIADB_DATABASE: 'database'
+      MARIADB_USER: 'user'
+      MARIADB_PASSWORD: 'password'
+      MARIADB_ROOT_PASSWORD: 'password'
+    ports:
+      - "3306:3306"
+    volumes:
+      - mariadb_data:/var/lib/mysql`}
+}
+
+func (i *DatabaseMySQLExtra) ComposerVolumes() []string {
+	return []string{`  mariadb_data:`}
+}
+
+func (i *DatabaseMySQLExtra) RequiredExtraTypes() ExtraTypes {
+	return ExtraTypes{}
+}
+
+func (i *DatabaseMySQLExtra) OneOfExtraTypes() ExtraTypes {
+	return ExtraTypes{}
+}
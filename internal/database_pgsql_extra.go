@@ -64,11 +64,19 @@ func (i *DatabasePgSQLExtra) GetExtraPersistentFlags() []string {
 		"rootCmd.PersistentFlags().String(\"DBUser\", \"user\", \"the database user\")",
 		"rootCmd.PersistentFlags().String(\"DBPass\", \"password\", \"the database password\")",
 		"rootCmd.PersistentFlags().String(\"DBName\", \"database\", \"the database name\")",
+		"rootCmd.PersistentFlags().StringSlice(\"DBReplicaHosts\", nil, \"the hosts of the database read replicas\")",
+		"rootCmd.PersistentFlags().Int(\"DBMaxOpenConns\", bat.DefaultDatabaseMaxOpenConns, \"the maximum number of open database connections\")",
+		"rootCmd.PersistentFlags().Int(\"DBMaxIdleConns\", bat.DefaultDatabaseMaxIdleConns, \"the maximum number of idle database connections\")",
+		"rootCmd.PersistentFlags().Duration(\"DBConnMaxLifetime\", bat.DefaultDatabaseConnMaxLifetime, \"how long a database connection may be reused\")",
 		"viper.BindPFlag(\"DB_HOST\", rootCmd.PersistentFlags().Lookup(\"DBHost\"))",
 		"viper.BindPFlag(\"DB_PORT\", rootCmd.PersistentFlags().Lookup(\"DBPort\"))",
 		"viper.BindPFlag(\"DB_USER\", rootCmd.PersistentFlags().Lookup(\"DBUser\"))",
 		"viper.BindPFlag(\"DB_PASS\", rootCmd.PersistentFlags().Lookup(\"DBPass\"))",
 		"viper.BindPFlag(\"DB_NAME\", rootCmd.PersistentFlags().Lookup(\"DBName\"))",
+		"viper.BindPFlag(\"DB_REPLICA_HOSTS\", rootCmd.PersistentFlags().Lookup(\"DBReplicaHosts\"))",
+		"viper.BindPFlag(\"DB_MAX_OPEN_CONNS\", rootCmd.PersistentFlags().Lookup(\"DBMaxOpenConns\"))",
+		"viper.BindPFlag(\"DB_MAX_IDLE_CONNS\", rootCmd.PersistentFlags().Lookup(\"DBMaxIdleConns\"))",
+		"viper.BindPFlag(\"DB_CONN_MAX_LIFETIME\", rootCmd.PersistentFlags().Lookup(\"DBConnMaxLifetime\"))",
 	}
 }
 
@@ -76,8 +84,12 @@ func (i *DatabasePgSQLExtra) ExtraType() ExtraType {
 	return DatabasePgSQL
 }
 
+func (i *DatabasePgSQLExtra) Dialect() Dialect {
+	return postgresDialect{}
+}
+
 func (i *DatabasePgSQLExtra) DisallowedExtraTypes() []ExtraType {
-	return []ExtraType{}
+	return []ExtraType{DatabaseSQLite, DatabaseMySQL}
 }
 
 func (i *DatabasePgSQLExtra) ComposerServices() []string {
@@ -0,0 +1,101 @@
+package internal
+
+import "github.com/JensvandeWiel/go-bat/internal/templates/database_sqlite_extra"
+
+type DatabaseSQLiteExtra struct {
+}
+
+func NewDatabaseSQLite() *DatabaseSQLiteExtra {
+	return &DatabaseSQLiteExtra{}
+}
+
+func (i *DatabaseSQLiteExtra) Generate(project *Project) error {
+	err := project.writeStringTemplateToFile("database/connect.go", database_sqlite_extra.DatabaseConnectTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("database/migrations/migrations.go", database_sqlite_extra.DatabaseMigrationsTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("database/migrations/20250210123935_placeholder.sql", database_sqlite_extra.DatabaseMigrationsPlaceholderTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("cmd/migrate.go", database_sqlite_extra.MigrateTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.createDirectories([]string{"database/models", "test_helpers"})
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("test_helpers/setup_db.go", database_sqlite_extra.TestHelpersSetupDbTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (i *DatabaseSQLiteExtra) ModEntries() []string {
+	return []string{
+		"github.com/jmoiron/sqlx v1.4.0",
+		"github.com/pressly/goose/v3 v3.24.1",
+		"modernc.org/sqlite v1.34.1",
+	}
+}
+
+func (i *DatabaseSQLiteExtra) GitIgnoreEntries() []string {
+	return []string{
+		"*.db",
+		"*.db-shm",
+		"*.db-wal",
+	}
+}
+
+func (i *DatabaseSQLiteExtra) GetExtraPersistentFlags() []string {
+	return []string{
+		"rootCmd.PersistentFlags().String(\"DBPath\", \"database.db\", \"the path of the database file\")",
+		"rootCmd.PersistentFlags().Int(\"DBMaxOpenConns\", bat.DefaultDatabaseMaxOpenConns, \"the maximum number of open database connections\")",
+		"rootCmd.PersistentFlags().Int(\"DBMaxIdleConns\", bat.DefaultDatabaseMaxIdleConns, \"the maximum number of idle database connections\")",
+		"rootCmd.PersistentFlags().Duration(\"DBConnMaxLifetime\", bat.DefaultDatabaseConnMaxLifetime, \"how long a database connection may be reused\")",
+		"viper.BindPFlag(\"DB_PATH\", rootCmd.PersistentFlags().Lookup(\"DBPath\"))",
+		"viper.BindPFlag(\"DB_MAX_OPEN_CONNS\", rootCmd.PersistentFlags().Lookup(\"DBMaxOpenConns\"))",
+		"viper.BindPFlag(\"DB_MAX_IDLE_CONNS\", rootCmd.PersistentFlags().Lookup(\"DBMaxIdleConns\"))",
+		"viper.BindPFlag(\"DB_CONN_MAX_LIFETIME\", rootCmd.PersistentFlags().Lookup(\"DBConnMaxLifetime\"))",
+	}
+}
+
+func (i *DatabaseSQLiteExtra) ExtraType() ExtraType {
+	return DatabaseSQLite
+}
+
+func (i *DatabaseSQLiteExtra) Dialect() Dialect {
+	return sqliteDialect{}
+}
+
+func (i *DatabaseSQLiteExtra) DisallowedExtraTypes() []ExtraType {
+	return []ExtraType{DatabasePgSQL, DatabaseMySQL}
+}
+
+func (i *DatabaseSQLiteExtra) ComposerServices() []string {
+	return []string{}
+}
+
+func (i *DatabaseSQLiteExtra) ComposerVolumes() []string {
+	return []string{}
+}
+
+func (i *DatabaseSQLiteExtra) RequiredExtraTypes() ExtraTypes {
+	return ExtraTypes{}
+}
+
+func (i *DatabaseSQLiteExtra) OneOfExtraTypes() ExtraTypes {
+	return ExtraTypes{}
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Dialect describes the SQL of a database, the generators use it to write migrations and stores that work with the
+// database extra of the project
+type Dialect interface {
+	// Placeholder returns the query placeholder of the nth argument, starting at 1
+	Placeholder(n int) string
+	// IDColumnType returns the column type of an auto incrementing id
+	IDColumnType() string
+	// ColumnType returns the column type of a model field type
+	ColumnType(fieldType string) string
+	// IndexesForeignKeys returns whether the database creates an index for every foreign key itself
+	IndexesForeignKeys() bool
+	// ReturnsInsertID returns whether the id of an inserted row is read with RETURNING instead of LastInsertId
+	ReturnsInsertID() bool
+	// DefaultValues returns the part of an INSERT statement that inserts a row with only default values
+	DefaultValues() string
+}
+
+// DatabaseExtra is an extra that provides the database of the project
+type DatabaseExtra interface {
+	Extra
+	// Dialect returns the SQL dialect of the database
+	Dialect() Dialect
+}
+
+// databaseDialect returns the dialect of the database extra of the project
+func (p *Project) databaseDialect() (Dialect, error) {
+	for _, extra := range ParseExtras(p.ExtraTypes) {
+		if databaseExtra, ok := extra.(DatabaseExtra); ok {
+			return databaseExtra.Dialect(), nil
+		}
+	}
+	return nil, fmt.Errorf("database extra is not in use")
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgresDialect) IDColumnType() string {
+	return "SERIAL NOT NULL"
+}
+
+func (postgresDialect) ColumnType(fieldType string) string {
+	switch fieldType {
+	case "string":
+		return "VARCHAR(255)"
+	case "text":
+		return "TEXT"
+	case "bigint":
+		return "BIGINT"
+	case "float":
+		return "DOUBLE PRECISION"
+	case "bool":
+		return "BOOLEAN"
+	case "time":
+		return "TIMESTAMPTZ"
+	default:
+		// The ids are SERIAL, so references are INTEGER too
+		return "INTEGER"
+	}
+}
+
+func (postgresDialect) IndexesForeignKeys() bool {
+	return false
+}
+
+// ReturnsInsertID returns true, lib/pq does not support LastInsertId
+func (postgresDialect) ReturnsInsertID() bool {
+	return true
+}
+
+func (postgresDialect) DefaultValues() string {
+	return "DEFAULT VALUES"
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mysqlDialect) IDColumnType() string {
+	return "BIGINT NOT NULL AUTO_INCREMENT"
+}
+
+func (mysqlDialect) ColumnType(fieldType string) string {
+	switch fieldType {
+	case "string":
+		return "VARCHAR(255)"
+	case "text":
+		return "TEXT"
+	case "int":
+		return "INT"
+	case "float":
+		return "DOUBLE"
+	case "bool":
+		return "BOOLEAN"
+	case "time":
+		return "DATETIME(6)"
+	default:
+		return "BIGINT"
+	}
+}
+
+func (mysqlDialect) IndexesForeignKeys() bool {
+	return true
+}
+
+func (mysqlDialect) ReturnsInsertID() bool {
+	return false
+}
+
+func (mysqlDialect) DefaultValues() string {
+	return "() VALUES ()"
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+// IDColumnType returns INTEGER, so the id is an alias of the rowid and gets the next rowid on insert
+func (sqliteDialect) IDColumnType() string {
+	return "INTEGER NOT NULL"
+}
+
+// ColumnType returns types the driver recognizes, so times and booleans are scanned back into their Go types
+func (sqliteDialect) ColumnType(fieldType string) string {
+	switch fieldType {
+	case "string", "text":
+		return "TEXT"
+	case "float":
+		return "REAL"
+	case "bool":
+		return "BOOLEAN"
+	case "time":
+		return "DATETIME"
+	default:
+		return "INTEGER"
+	}
+}
+
+func (sqliteDialect) IndexesForeignKeys() bool {
+	return false
+}
+
+func (sqliteDialect) ReturnsInsertID() bool {
+	return false
+}
+
+func (sqliteDialect) DefaultValues() string {
+	return "DEFAULT VALUES"
+}
@@ -41,10 +41,15 @@ func (t ExtraType) String() string {
 }
 
 const (
-	InertiaReact  ExtraType = "inertia-react"
-	InertiaSvelte ExtraType = "inertia-svelte"
-	DatabasePgSQL ExtraType = "database-pgsql"
-	FrontendAuth  ExtraType = "frontend-auth"
+	InertiaReact   ExtraType = "inertia-react"
+	InertiaSvelte  ExtraType = "inertia-svelte"
+	InertiaVue     ExtraType = "inertia-vue"
+	DatabasePgSQL  ExtraType = "database-pgsql"
+	DatabaseSQLite ExtraType = "database-sqlite"
+	DatabaseMySQL  ExtraType = "database-mysql"
+	FrontendAuth   ExtraType = "frontend-auth"
+	Valkey         ExtraType = "valkey"
+	Jobs           ExtraType = "jobs"
 )
 
 func ParseExtraType(extra string) ExtraType {
@@ -53,10 +58,20 @@ func ParseExtraType(extra string) ExtraType {
 		return InertiaReact
 	case "inertia-svelte":
 		return InertiaSvelte
+	case "inertia-vue":
+		return InertiaVue
 	case "database-pgsql":
 		return DatabasePgSQL
+	case "database-sqlite":
+		return DatabaseSQLite
+	case "database-mysql":
+		return DatabaseMySQL
 	case "frontend-auth":
 		return FrontendAuth
+	case "valkey":
+		return Valkey
+	case "jobs":
+		return Jobs
 	default:
 		return ""
 	}
@@ -79,10 +94,20 @@ func ParseExtra(extra ExtraType) Extra {
 		return &InertiaReactExtra{}
 	case InertiaSvelte:
 		return &InertiaSvelteExtra{}
+	case InertiaVue:
+		return &InertiaVueExtra{}
 	case DatabasePgSQL:
 		return &DatabasePgSQLExtra{}
+	case DatabaseSQLite:
+		return &DatabaseSQLiteExtra{}
+	case DatabaseMySQL:
+		return &DatabaseMySQLExtra{}
 	case FrontendAuth:
 		return &FrontendAuthServiceExtra{}
+	case Valkey:
+		return &ValkeyExtra{}
+	case Jobs:
+		return &JobsExtra{}
 	default:
 		return nil
 	}
@@ -43,6 +43,12 @@ func (f *FrontendAuthServiceExtra) Generate(project *Project) error {
 
 	version := generateTimestamp()
 
+	// The fields of the users table without defaults, the store tests of models that reference users insert them
+	project.Models = append(project.Models, ProjectModel{
+		Table:  "users",
+		Fields: []string{"email:string:unique", "name:string:required", "password:string:required"},
+	})
+
 	err = project.writeStringTemplateToFile("database/migrations/"+version+"_create_users_table.sql", frontend_auth_service.CreateUserTableUpMigration, data)
 	if err != nil {
 		return err
@@ -94,5 +100,5 @@ func (f *FrontendAuthServiceExtra) RequiredExtraTypes() ExtraTypes {
 }
 
 func (f *FrontendAuthServiceExtra) OneOfExtraTypes() ExtraTypes {
-	return ExtraTypes{InertiaReact, InertiaSvelte}
+	return ExtraTypes{InertiaReact, InertiaSvelte, InertiaVue}
 }
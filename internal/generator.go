@@ -4,8 +4,20 @@ import (
 	"fmt"
 )
 
+// GenerateOptions are the flags of the generate command
+type GenerateOptions struct {
+	// Extra generates extra files like tests
+	Extra bool
+	// Resource generates the resource routes of a controller
+	Resource bool
+	// Global registers a middleware for all routes
+	Global bool
+	// Controller is the name of the controller a middleware is registered for
+	Controller string
+}
+
 type Generator interface {
-	Generate(name string, extra bool) error
+	Generate(name string, args []string, opts GenerateOptions) error
 }
 
 func ParseGenerator(name string, project *Project) (Generator, error) {
@@ -15,6 +27,22 @@ func ParseGenerator(name string, project *Project) (Generator, error) {
 		return &ModelGenerator{
 			project: project,
 		}, nil
+	case "controller":
+		return &ControllerGenerator{
+			project: project,
+		}, nil
+	case "middleware":
+		return &MiddlewareGenerator{
+			project: project,
+		}, nil
+	case "request":
+		return &RequestGenerator{
+			project: project,
+		}, nil
+	case "scaffold":
+		return &ScaffoldGenerator{
+			project: project,
+		}, nil
 	default:
 		project.logger.Error("Unknown generator", "name", name)
 		return nil, fmt.Errorf("unknown generator %q", name)
@@ -1,6 +1,9 @@
 package internal
 
-import "github.com/JensvandeWiel/go-bat/internal/templates/inertia_react_extra"
+import (
+	"github.com/JensvandeWiel/go-bat/internal/templates/generators"
+	"github.com/JensvandeWiel/go-bat/internal/templates/inertia_react_extra"
+)
 
 type InertiaReactExtra struct {
 }
@@ -31,10 +34,7 @@ func (i *InertiaReactExtra) Generate(project *Project) error {
 }
 
 func (i *InertiaReactExtra) ModEntries() []string {
-	return []string{
-		"github.com/romsar/gonertia/v2 v2.0.3",
-		"github.com/valkey-io/valkey-go v1.0.54",
-	}
+	return []string{}
 }
 
 func (i *InertiaReactExtra) GitIgnoreEntries() []string {
@@ -42,12 +42,7 @@ func (i *InertiaReactExtra) GitIgnoreEntries() []string {
 }
 
 func (i *InertiaReactExtra) GetExtraPersistentFlags() []string {
-	return []string{
-		"rootCmd.PersistentFlags().String(\"CACHE_HOST\", \"localhost\", \"the cache host\")",
-		"rootCmd.PersistentFlags().Int(\"CACHE_PORT\", 6379, \"the cache port\")",
-		"viper.BindPFlag(\"CACHE_HOST\", rootCmd.PersistentFlags().Lookup(\"CACHE_HOST\"))",
-		"viper.BindPFlag(\"CACHE_PORT\", rootCmd.PersistentFlags().Lookup(\"CACHE_PORT\"))",
-	}
+	return []string{}
 }
 
 func (i *InertiaReactExtra) ExtraType() ExtraType {
@@ -55,27 +50,33 @@ func (i *InertiaReactExtra) ExtraType() ExtraType {
 }
 
 func (i *InertiaReactExtra) DisallowedExtraTypes() []ExtraType {
-	return []ExtraType{InertiaSvelte}
+	return []ExtraType{InertiaSvelte, InertiaVue}
 }
 
 func (i *InertiaReactExtra) ComposerServices() []string {
-	return []string{`  valkey:
-    image: valkey/valkey:8
-    ports:
-      - "6379:6379"
-    volumes:
-      - valkey_data:/data
-`}
+	return []string{}
 }
 
 func (i *InertiaReactExtra) ComposerVolumes() []string {
-	return []string{`  valkey_data:`}
+	return []string{}
 }
 
 func (i *InertiaReactExtra) RequiredExtraTypes() ExtraTypes {
-	return ExtraTypes{}
+	return ExtraTypes{Valkey}
 }
 
 func (i *InertiaReactExtra) OneOfExtraTypes() ExtraTypes {
 	return ExtraTypes{}
 }
+
+func (i *InertiaReactExtra) PageExtension() string {
+	return ".tsx"
+}
+
+func (i *InertiaReactExtra) PageTemplate() string {
+	return generators.ControllerReactPageTemplate
+}
+
+func (i *InertiaReactExtra) ScaffoldPagesDir() string {
+	return "scaffold/pages/react"
+}
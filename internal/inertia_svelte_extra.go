@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"github.com/JensvandeWiel/go-bat/internal/templates/generators"
 	"github.com/JensvandeWiel/go-bat/internal/templates/inertia_svelte_extra"
 )
 
@@ -33,10 +34,7 @@ func (i *InertiaSvelteExtra) Generate(project *Project) error {
 }
 
 func (i *InertiaSvelteExtra) ModEntries() []string {
-	return []string{
-		"github.com/romsar/gonertia/v2 v2.0.3",
-		"github.com/valkey-io/valkey-go v1.0.54",
-	}
+	return []string{}
 }
 
 func (i *InertiaSvelteExtra) GitIgnoreEntries() []string {
@@ -44,12 +42,7 @@ func (i *InertiaSvelteExtra) GitIgnoreEntries() []string {
 }
 
 func (i *InertiaSvelteExtra) GetExtraPersistentFlags() []string {
-	return []string{
-		"rootCmd.PersistentFlags().String(\"CACHE_HOST\", \"localhost\", \"the cache host\")",
-		"rootCmd.PersistentFlags().Int(\"CACHE_PORT\", 6379, \"the cache port\")",
-		"viper.BindPFlag(\"CACHE_HOST\", rootCmd.PersistentFlags().Lookup(\"CACHE_HOST\"))",
-		"viper.BindPFlag(\"CACHE_PORT\", rootCmd.PersistentFlags().Lookup(\"CACHE_PORT\"))",
-	}
+	return []string{}
 }
 
 func (i *InertiaSvelteExtra) ExtraType() ExtraType {
@@ -57,27 +50,33 @@ func (i *InertiaSvelteExtra) ExtraType() ExtraType {
 }
 
 func (i *InertiaSvelteExtra) DisallowedExtraTypes() []ExtraType {
-	return []ExtraType{InertiaReact}
+	return []ExtraType{InertiaReact, InertiaVue}
 }
 
 func (i *InertiaSvelteExtra) ComposerServices() []string {
-	return []string{`  valkey:
-    image: valkey/valkey:8
-    ports:
-      - "6379:6379"
-    volumes:
-      - valkey_data:/data
-`}
+	return []string{}
 }
 
 func (i *InertiaSvelteExtra) ComposerVolumes() []string {
-	return []string{`  valkey_data:`}
+	return []string{}
 }
 
 func (i *InertiaSvelteExtra) RequiredExtraTypes() ExtraTypes {
-	return ExtraTypes{}
+	return ExtraTypes{Valkey}
 }
 
 func (i *InertiaSvelteExtra) OneOfExtraTypes() ExtraTypes {
 	return ExtraTypes{}
 }
+
+func (i *InertiaSvelteExtra) PageExtension() string {
+	return ".svelte"
+}
+
+func (i *InertiaSvelteExtra) PageTemplate() string {
+	return generators.ControllerSveltePageTemplate
+}
+
+func (i *InertiaSvelteExtra) ScaffoldPagesDir() string {
+	return "scaffold/pages/svelte"
+}
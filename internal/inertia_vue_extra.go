@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"github.com/JensvandeWiel/go-bat/internal/templates/generators"
+	"github.com/JensvandeWiel/go-bat/internal/templates/inertia_vue_extra"
+)
+
+type InertiaVueExtra struct {
+}
+
+func NewInertiaVueExtra() *InertiaVueExtra {
+	return &InertiaVueExtra{}
+}
+
+func (i *InertiaVueExtra) Generate(project *Project) error {
+	err := project.writeStringTemplateToFile("controllers/inertia_controller.go", inertia_vue_extra.ControllersInertiaController, nil)
+	if err != nil {
+		return err
+	}
+
+	err = project.copyEmbeddedFiles(inertia_vue_extra.Frontend, "frontend", "frontend", func(s string) string {
+		if s == "frontend.go.tmpl" {
+			return "frontend.go"
+		} else if s == "gitignore.tmpl" {
+			return ".gitignore"
+		}
+		return s
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (i *InertiaVueExtra) ModEntries() []string {
+	return []string{}
+}
+
+func (i *InertiaVueExtra) GitIgnoreEntries() []string {
+	return []string{}
+}
+
+func (i *InertiaVueExtra) GetExtraPersistentFlags() []string {
+	return []string{}
+}
+
+func (i *InertiaVueExtra) ExtraType() ExtraType {
+	return InertiaVue
+}
+
+func (i *InertiaVueExtra) DisallowedExtraTypes() []ExtraType {
+	return []ExtraType{InertiaReact, InertiaSvelte}
+}
+
+func (i *InertiaVueExtra) ComposerServices() []string {
+	return []string{}
+}
+
+func (i *InertiaVueExtra) ComposerVolumes() []string {
+	return []string{}
+}
+
+func (i *InertiaVueExtra) RequiredExtraTypes() ExtraTypes {
+	return ExtraTypes{Valkey}
+}
+
+func (i *InertiaVueExtra) OneOfExtraTypes() ExtraTypes {
+	return ExtraTypes{}
+}
+
+func (i *InertiaVueExtra) PageExtension() string {
+	return ".vue"
+}
+
+func (i *InertiaVueExtra) PageTemplate() string {
+	return generators.ControllerVuePageTemplate
+}
+
+func (i *InertiaVueExtra) ScaffoldPagesDir() string {
+	return "scaffold/pages/vue"
+}
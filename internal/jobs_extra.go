@@ -0,0 +1,73 @@
+package internal
+
+import "github.com/JensvandeWiel/go-bat/internal/templates/jobs_extra"
+
+type JobsExtra struct {
+}
+
+func NewJobsExtra() *JobsExtra {
+	return &JobsExtra{}
+}
+
+func (j *JobsExtra) Generate(project *Project) error {
+	err := project.writeStringTemplateToFile("cmd/worker.go", jobs_extra.WorkerTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("jobs/jobs.go", jobs_extra.JobsTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("jobs/jobs_test.go", jobs_extra.JobsTestTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	err = project.writeStringTemplateToFile("tasks/tasks.go", jobs_extra.TasksTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (j *JobsExtra) ModEntries() []string {
+	return []string{}
+}
+
+func (j *JobsExtra) GitIgnoreEntries() []string {
+	return []string{}
+}
+
+func (j *JobsExtra) GetExtraPersistentFlags() []string {
+	return []string{
+		"rootCmd.PersistentFlags().Int(\"WORKER_CONCURRENCY\", 10, \"the amount of jobs a worker runs at the same time\")",
+		"viper.BindPFlag(\"WORKER_CONCURRENCY\", rootCmd.PersistentFlags().Lookup(\"WORKER_CONCURRENCY\"))",
+	}
+}
+
+func (j *JobsExtra) ExtraType() ExtraType {
+	return Jobs
+}
+
+func (j *JobsExtra) DisallowedExtraTypes() []ExtraType {
+	return []ExtraType{}
+}
+
+func (j *JobsExtra) ComposerServices() []string {
+	return []string{}
+}
+
+func (j *JobsExtra) ComposerVolumes() []string {
+	return []string{}
+}
+
+func (j *JobsExtra) RequiredExtraTypes() ExtraTypes {
+	return ExtraTypes{Valkey}
+}
+
+func (j *JobsExtra) OneOfExtraTypes() ExtraTypes {
+	return ExtraTypes{}
+}
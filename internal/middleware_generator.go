@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/JensvandeWiel/go-bat/internal/templates/generators"
+	"github.com/iancoleman/strcase"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// routeMethods are the methods of echo that add routes or groups, their last arguments are middleware
+var routeMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "CONNECT", "TRACE", "Any", "Match", "Group"}
+
+var registerControllersRegex = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\.RegisterControllers\(`)
+
+type MiddlewareGenerator struct {
+	project *Project
+}
+
+func (m *MiddlewareGenerator) Generate(name string, args []string, opts GenerateOptions) error {
+	name = strcase.ToCamel(strings.TrimSuffix(strcase.ToCamel(name), "Middleware"))
+	if name == "" {
+		return fmt.Errorf("invalid middleware name")
+	}
+	if opts.Global && opts.Controller != "" {
+		return fmt.Errorf("a middleware is registered globally or for a controller, not both")
+	}
+
+	middlewareFile := path.Join("middleware", strcase.ToSnake(name)+"_middleware.go")
+	if _, err := os.Stat(path.Join(m.project.tempDir, middlewareFile)); err == nil {
+		m.project.logger.Error("middleware file already exists", "middlewareFile", middlewareFile)
+		return fmt.Errorf("middleware file already exists: %s", middlewareFile)
+	}
+
+	// The middleware is registered first, so nothing is generated for a controller that does not exist
+	switch {
+	case opts.Global:
+		err := m.project.registerGlobalMiddleware(name)
+		if err != nil {
+			m.project.logger.Error("Failed to register the middleware in cmd/serve.go", "error", err)
+			return err
+		}
+		m.project.logger.Info("Registered middleware for all routes", "middleware", name)
+	case opts.Controller != "":
+		controller := strcase.ToCamel(strings.TrimSuffix(strcase.ToCamel(opts.Controller), "Controller")) + "Controller"
+		err := m.project.registerControllerMiddleware(name, controller)
+		if err != nil {
+			m.project.logger.Error("Failed to register the middleware for the controller", "controller", controller, "error", err)
+			return err
+		}
+		m.project.logger.Info("Registered middleware for the routes of the controller", "middleware", name, "controller", controller)
+	default:
+		m.project.logger.Info("Register the middleware in cmd/serve.go or on the routes of a controller", "middleware", name)
+	}
+
+	data := map[string]interface{}{
+		"PackageName": m.project.PackageName,
+		"name":        name,
+		"module":      strcase.ToSnake(name) + "_middleware",
+	}
+
+	err := os.MkdirAll(path.Join(m.project.tempDir, "middleware"), 0755)
+	if err != nil {
+		return err
+	}
+	err = m.project.writeStringTemplateToFile(middlewareFile, generators.MiddlewareTemplate, data)
+	if err != nil {
+		return err
+	}
+	err = m.project.writeStringTemplateToFile(path.Join("middleware", strcase.ToSnake(name)+"_middleware_test.go"), generators.MiddlewareTestTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	m.project.logger.Info("Generated middleware", "middlewareFile", middlewareFile)
+	return nil
+}
+
+// registerGlobalMiddleware adds the middleware to the app before the controllers are registered in cmd/serve.go
+func (p *Project) registerGlobalMiddleware(name string) error {
+	servePath := path.Join(p.tempDir, "cmd", "serve.go")
+	content, err := os.ReadFile(servePath)
+	if err != nil {
+		return err
+	}
+
+	// cmd/serve.go imports the middleware of echo, so the package of the project may need an alias
+	content, pkgName, err := ensureImport(content, p.PackageName+"/middleware", "middleware")
+	if err != nil {
+		return err
+	}
+	src := string(content)
+
+	match := registerControllersRegex.FindStringSubmatchIndex(src)
+	if match == nil {
+		return fmt.Errorf("RegisterControllers call not found in cmd/serve.go")
+	}
+	app := src[match[2]:match[3]]
+	use := fmt.Sprintf("%s.Use(%s.%s(%s))", app, pkgName, name, app)
+	if strings.Contains(src, use) {
+		return nil
+	}
+
+	lineStart := strings.LastIndex(src[:match[0]], "\n") + 1
+	indent := src[lineStart : lineStart+len(src[lineStart:])-len(strings.TrimLeft(src[lineStart:], " \t"))]
+	formatted, err := format.Source([]byte(src[:lineStart] + indent + use + "\n\n" + src[lineStart:]))
+	if err != nil {
+		return fmt.Errorf("failed to format cmd/serve.go: %w", err)
+	}
+	return os.WriteFile(servePath, formatted, 0644)
+}
+
+// registerControllerMiddleware adds the middleware to every route the Register method of the controller adds
+func (p *Project) registerControllerMiddleware(name, controller string) error {
+	files, err := filepath.Glob(path.Join(p.tempDir, "controllers", "*.go"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, file, content, 0)
+		if err != nil {
+			return err
+		}
+		register := findRegisterMethod(f, controller)
+		if register == nil {
+			continue
+		}
+		if len(register.Type.Params.List) == 0 || len(register.Type.Params.List[0].Names) == 0 {
+			return fmt.Errorf("register method of %s has no app parameter", controller)
+		}
+		app := register.Type.Params.List[0].Names[0].Name
+
+		content, pkgName, err := ensureImport(content, p.PackageName+"/middleware", "middleware")
+		if err != nil {
+			return err
+		}
+		// The import moved the code, so the offsets of the calls are taken from the new source
+		fset = token.NewFileSet()
+		f, err = parser.ParseFile(fset, file, content, 0)
+		if err != nil {
+			return err
+		}
+		register = findRegisterMethod(f, controller)
+		middleware := fmt.Sprintf("%s.%s(%s)", pkgName, name, app)
+
+		var offsets []int
+		ast.Inspect(register.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			selector, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if x, ok := selector.X.(*ast.Ident); !ok || x.Name != app || !slices.Contains(routeMethods, selector.Sel.Name) {
+				return true
+			}
+			callSrc := string(content[fset.Position(call.Pos()).Offset:fset.Position(call.End()).Offset])
+			if !strings.Contains(callSrc, middleware) {
+				offsets = append(offsets, fset.Position(call.Rparen).Offset)
+			}
+			return true
+		})
+		if len(offsets) == 0 {
+			return fmt.Errorf("no routes without the middleware found in the register method of %s", controller)
+		}
+
+		// Insert from the end, so the offsets before it stay valid
+		sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+		src := string(content)
+		for _, offset := range offsets {
+			src = src[:offset] + ", " + middleware + src[offset:]
+		}
+		formatted, err := format.Source([]byte(src))
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", file, err)
+		}
+		return os.WriteFile(file, formatted, 0644)
+	}
+	return fmt.Errorf("controller %s not found in controllers", controller)
+}
+
+// findRegisterMethod returns the Register method of the controller type in the file, or nil
+func findRegisterMethod(f *ast.File, controller string) *ast.FuncDecl {
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "Register" || fn.Recv == nil || len(fn.Recv.List) == 0 || fn.Body == nil {
+			continue
+		}
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if ident, ok := recv.(*ast.Ident); ok && ident.Name == controller {
+			return fn
+		}
+	}
+	return nil
+}
+
+// ensureImport adds the import to the Go source and returns the name to use the package with. When the file already
+// imports another package with the name, the import gets the name with an app prefix.
+func ensureImport(src []byte, importPath, name string) ([]byte, string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly)
+	if err != nil {
+		return nil, "", err
+	}
+
+	taken := false
+	for _, spec := range f.Imports {
+		specPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, "", err
+		}
+		specName := path.Base(specPath)
+		if spec.Name != nil {
+			specName = spec.Name.Name
+		}
+		if specPath == importPath {
+			return src, specName, nil
+		}
+		taken = taken || specName == name
+	}
+
+	line := strconv.Quote(importPath)
+	if taken {
+		name = "app" + name
+		line = name + " " + line
+	}
+
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT || !gen.Lparen.IsValid() {
+			continue
+		}
+		offset := fset.Position(gen.Lparen).Offset + 1
+		return []byte(string(src[:offset]) + "\n\t" + line + string(src[offset:])), name, nil
+	}
+	// Without an import block the import is added after the package clause
+	offset := fset.Position(f.Name.End()).Offset
+	return []byte(string(src[:offset]) + "\n\nimport " + line + string(src[offset:])), name, nil
+}
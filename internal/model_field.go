@@ -0,0 +1,294 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/iancoleman/strcase"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var fieldNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// goFieldTypes are the supported field types and their Go types
+var goFieldTypes = map[string]string{
+	"string": "string",
+	"text":   "string",
+	"int":    "int",
+	"bigint": "int64",
+	"float":  "float64",
+	"bool":   "bool",
+	"time":   "time.Time",
+	"ref":    "int64",
+}
+
+// ModelField is a field of a generated model, parsed from a spec like title:string:required or author_id:ref:users.
+// A type ending in ? makes the field nullable.
+type ModelField struct {
+	// Name is the column name
+	Name string
+	// Type is the field type, one of the keys of goFieldTypes
+	Type     string
+	Nullable bool
+	Required bool
+	Unique   bool
+	Index    bool
+	// RefTable is the table a ref field references
+	RefTable string
+}
+
+// ParseModelField parses a field spec
+func ParseModelField(spec string) (ModelField, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 {
+		return ModelField{}, fmt.Errorf("invalid field %q, expected name:type[:modifiers]", spec)
+	}
+
+	field := ModelField{Name: parts[0], Type: parts[1]}
+	if !fieldNameRegex.MatchString(field.Name) {
+		return ModelField{}, fmt.Errorf("invalid field name %q, use lowercase letters, digits and underscores", field.Name)
+	}
+	if field.Name == "id" {
+		return ModelField{}, fmt.Errorf("field id is always generated")
+	}
+	if strings.HasSuffix(field.Type, "?") {
+		field.Nullable = true
+		field.Type = strings.TrimSuffix(field.Type, "?")
+	}
+	if _, ok := goFieldTypes[field.Type]; !ok {
+		return ModelField{}, fmt.Errorf("unknown type %q of field %s", field.Type, field.Name)
+	}
+
+	modifiers := parts[2:]
+	if field.Type == "ref" {
+		if len(modifiers) == 0 || modifiers[0] == "" {
+			return ModelField{}, fmt.Errorf("ref field %s needs a table, like %s:ref:users", field.Name, field.Name)
+		}
+		field.RefTable = modifiers[0]
+		if !fieldNameRegex.MatchString(field.RefTable) {
+			return ModelField{}, fmt.Errorf("invalid table %q of field %s, use lowercase letters, digits and underscores", field.RefTable, field.Name)
+		}
+		modifiers = modifiers[1:]
+	}
+	for _, modifier := range modifiers {
+		switch modifier {
+		case "required":
+			field.Required = true
+		case "unique":
+			field.Unique = true
+		case "index":
+			field.Index = true
+		default:
+			return ModelField{}, fmt.Errorf("unknown modifier %q of field %s", modifier, field.Name)
+		}
+	}
+	if field.Type == "text" && (field.Unique || field.Index) {
+		return ModelField{}, fmt.Errorf("text field %s can not be indexed, use a string field", field.Name)
+	}
+	if field.Required && field.Nullable {
+		return ModelField{}, fmt.Errorf("field %s can not be required and nullable", field.Name)
+	}
+	return field, nil
+}
+
+// ParseModelFields parses the field specs, the field names must be unique
+func ParseModelFields(specs []string) ([]ModelField, error) {
+	fields := make([]ModelField, 0, len(specs))
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		field, err := ParseModelField(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("field %s is defined twice", field.Name)
+		}
+		seen[field.Name] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// GoName returns the name of the struct field
+func (f ModelField) GoName() string {
+	return strcase.ToCamel(f.Name)
+}
+
+// ParamName returns the name of a function parameter for the field
+func (f ModelField) ParamName() string {
+	name := strcase.ToLowerCamel(f.Name)
+	if token.IsKeyword(name) {
+		return name + "Value"
+	}
+	return name
+}
+
+// BaseGoType returns the Go type of the field without the pointer of a nullable field
+func (f ModelField) BaseGoType() string {
+	return goFieldTypes[f.Type]
+}
+
+// GoType returns the Go type of the struct field, nullable fields are pointers
+func (f ModelField) GoType() string {
+	if f.Nullable {
+		return "*" + f.BaseGoType()
+	}
+	return f.BaseGoType()
+}
+
+// Tags returns the struct tags of the field
+func (f ModelField) Tags() string {
+	return fmt.Sprintf("`json:\"%s\" db:\"%s\"`", f.Name, f.Name)
+}
+
+// RequestTags returns the struct tags of the field in the request of the model in table, with the rules of bat.Validator
+func (f ModelField) RequestTags(table string) string {
+	var rules []string
+	if f.Required || f.Type == "ref" && !f.Nullable {
+		rules = append(rules, "required")
+	}
+	if f.Type == "string" {
+		rules = append(rules, "max=255")
+	}
+	if f.Unique {
+		rules = append(rules, "unique="+table)
+	}
+	tags := fmt.Sprintf("json:\"%s\" form:\"%s\"", f.Name, f.Name)
+	if len(rules) > 0 {
+		tags += fmt.Sprintf(" validate:\"%s\"", strings.Join(rules, ","))
+	}
+	return "`" + tags + "`"
+}
+
+// IsString returns whether the field holds text
+func (f ModelField) IsString() bool {
+	return f.Type == "string" || f.Type == "text"
+}
+
+// ColumnDefinition returns the column definition of the field in the CREATE TABLE statement
+func (f ModelField) ColumnDefinition(dialect Dialect) string {
+	definition := f.Name + " " + dialect.ColumnType(f.Type)
+	if f.Nullable {
+		definition += " NULL"
+	} else {
+		definition += " NOT NULL"
+	}
+	if f.Unique {
+		definition += " UNIQUE"
+	}
+	if f.Required && f.IsString() {
+		definition += fmt.Sprintf(" CHECK (length(%s) > 0)", f.Name)
+	}
+	return definition
+}
+
+// Filterable returns whether the stores can filter on the field, text, float and time fields can not be compared
+// for equality in a useful way
+func (f ModelField) Filterable() bool {
+	switch f.Type {
+	case "text", "float", "time":
+		return false
+	default:
+		return true
+	}
+}
+
+// Sortable returns whether the stores can sort on the field
+func (f ModelField) Sortable() bool {
+	return f.Type != "text"
+}
+
+// SampleValue returns a Go expression with the nth value for the field, it is used in the generated tests. The values
+// of different n differ, so rows with them do not violate unique constraints.
+func (f ModelField) SampleValue(n int) string {
+	if f.Nullable {
+		return "nil"
+	}
+	switch f.Type {
+	case "string", "text":
+		return fmt.Sprintf("%q", fmt.Sprintf("%s %d", f.Name, n))
+	case "float":
+		return fmt.Sprintf("%d.5", n)
+	case "bool":
+		return strconv.FormatBool(n%2 == 1)
+	case "time":
+		return fmt.Sprintf("time.Date(2025, time.January, %d, 0, 0, 0, 0, time.UTC)", n)
+	default:
+		// Ref fields reference the nth row of their table
+		return strconv.Itoa(n)
+	}
+}
+
+// Label returns the name of the field for people, like Published at for published_at
+func (f ModelField) Label() string {
+	label := strings.ReplaceAll(f.Name, "_", " ")
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// InputType returns the type of the form input of the field, text fields use a textarea
+func (f ModelField) InputType() string {
+	switch f.Type {
+	case "text":
+		return "textarea"
+	case "int", "bigint", "float", "ref":
+		return "number"
+	case "bool":
+		return "checkbox"
+	case "time":
+		return "datetime-local"
+	default:
+		return "text"
+	}
+}
+
+// FormInitial returns a TypeScript expression with the form value of the field of the model in variable, the variable
+// is undefined in create forms
+func (f ModelField) FormInitial(variable string) string {
+	value := variable + "?." + f.Name
+	switch f.Type {
+	case "bool":
+		return value + " ?? false"
+	case "time":
+		// datetime-local inputs take the time without seconds and zone, the times are kept in UTC
+		return value + "?.slice(0, 16) ?? ''"
+	default:
+		return value + " ?? ''"
+	}
+}
+
+// FormValue returns a TypeScript expression that converts the form value of the field in variable to the JSON value of
+// the request
+func (f ModelField) FormValue(variable string) string {
+	value := variable + "." + f.Name
+	var converted string
+	switch f.Type {
+	case "int", "bigint", "float", "ref":
+		converted = "Number(" + value + ")"
+	case "time":
+		converted = value + " + ':00Z'"
+	default:
+		converted = value
+	}
+	if f.Nullable && f.Type != "bool" {
+		return value + " === '' ? null : " + converted
+	}
+	return converted
+}
+
+// TSType returns the TypeScript type of the JSON value of the field
+func (f ModelField) TSType() string {
+	var tsType string
+	switch f.Type {
+	case "int", "bigint", "float", "ref":
+		tsType = "number"
+	case "bool":
+		tsType = "boolean"
+	default:
+		tsType = "string"
+	}
+	if f.Nullable {
+		return tsType + " | null"
+	}
+	return tsType
+}
@@ -8,6 +8,8 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -19,14 +21,78 @@ type ModelGenerator struct {
 
 var timestampFormat = "20060102150405"
 
+// fixtureNumbers are the n of the sample values of the fixtures of the store tests, and of the rows they reference
+var fixtureNumbers = []int{1, 2, 3}
+
 func generateTimestamp() string {
 	return time.Now().UTC().Format(timestampFormat)
 }
 
-func (m *ModelGenerator) Generate(name string, extra bool) error {
-	if !m.project.ExtraTypes.HasExtra(DatabasePgSQL) {
+// modelTemplateData returns the data of the templates of the files of a model
+func modelTemplateData(packageName, name string, fields []ModelField, dialect Dialect) map[string]interface{} {
+	var uniqueFields, refFields, indexedFields, filterFields, sortFields []ModelField
+	var refTables []string
+	// checkField is compared in the tests to see that a row was written, its sample values differ for every n
+	var checkField *ModelField
+	// The test only needs time for the sample values of time fields that are not nullable
+	testHasTime := false
+	for _, field := range fields {
+		testHasTime = testHasTime || field.Type == "time" && !field.Nullable
+		if field.Type == "ref" && !slices.Contains(refTables, field.RefTable) {
+			refTables = append(refTables, field.RefTable)
+		}
+		if field.Filterable() {
+			filterFields = append(filterFields, field)
+			if checkField == nil && !field.Nullable && field.Type != "bool" {
+				checkField = &field
+			}
+		}
+		if field.Sortable() {
+			sortFields = append(sortFields, field)
+		}
+		if field.Unique {
+			uniqueFields = append(uniqueFields, field)
+		}
+		if field.Type == "ref" {
+			refFields = append(refFields, field)
+		}
+		// Unique fields already have an index, and some databases index foreign keys themselves
+		if !field.Unique && (field.Index || field.Type == "ref" && !dialect.IndexesForeignKeys()) {
+			indexedFields = append(indexedFields, field)
+		}
+	}
+
+	return map[string]interface{}{
+		"pluralLowName":  pluralize.NewClient().Plural(strings.ToLower(name)),
+		"pluralName":     pluralize.NewClient().Plural(strcase.ToCamel(name)),
+		"PackageName":    packageName,
+		"modelName":      strcase.ToCamel(name),
+		"modelNameLow":   strings.ToLower(name),
+		"dialect":        dialect,
+		"fields":         fields,
+		"testHasTime":    testHasTime,
+		"uniqueFields":   uniqueFields,
+		"refFields":      refFields,
+		"refTables":      refTables,
+		"indexedFields":  indexedFields,
+		"filterFields":   filterFields,
+		"sortFields":     sortFields,
+		"checkField":     checkField,
+		"fixtureNumbers": fixtureNumbers,
+	}
+}
+
+func (m *ModelGenerator) Generate(name string, args []string, opts GenerateOptions) error {
+	dialect, err := m.project.databaseDialect()
+	if err != nil {
 		m.project.logger.Error("database extra is not in use")
-		return fmt.Errorf("database extra is not in use")
+		return err
+	}
+
+	fields, err := ParseModelFields(args)
+	if err != nil {
+		m.project.logger.Error("invalid fields", "error", err)
+		return err
 	}
 
 	modelDir := path.Join("database", "models")
@@ -41,27 +107,31 @@ func (m *ModelGenerator) Generate(name string, extra bool) error {
 		return fmt.Errorf("model file already exists: %s", modelFile)
 	}
 
+	hasTime := false
+	for _, field := range fields {
+		hasTime = hasTime || field.Type == "time"
+	}
+
 	data := struct {
-		Name string
+		Name    string
+		Fields  []ModelField
+		HasTime bool
 	}{
-		Name: strcase.ToCamel(name),
+		Name:    strcase.ToCamel(name),
+		Fields:  fields,
+		HasTime: hasTime,
 	}
 
-	err := m.project.writeStringTemplateToFile(modelFile, generators.ModelTemplate, data)
+	err = m.project.writeStringTemplateToFile(modelFile, generators.ModelTemplate, data)
 	if err != nil {
 		m.project.logger.Error("failed to write model file", "modelFile", modelFile, "error", err)
 		return err
 	}
 
-	if extra {
+	if opts.Extra {
 		m.project.logger.Info("Generating extra files for model")
 
-		data := map[string]interface{}{
-			"pluralLowName": pluralize.NewClient().Plural(strings.ToLower(name)),
-			"PackageName":   m.project.PackageName,
-			"modelName":     strcase.ToCamel(name),
-			"modelNameLow":  strings.ToLower(name),
-		}
+		data := modelTemplateData(m.project.PackageName, name, fields, dialect)
 		fileName := generateTimestamp() + "_create_" + strings.ToLower(name) + ".sql"
 		err := m.project.writeStringTemplateToFile(filepath.Join("database", "migrations", fileName), generators.ModelMigrationTemplate, data)
 		if err != nil {
@@ -73,13 +143,123 @@ func (m *ModelGenerator) Generate(name string, extra bool) error {
 			return err
 		}
 
+		// The fixtures reference rows of other tables, the test inserts them before the fixtures are created
+		seedRows, unknownTables, err := m.project.seedRows(data["refFields"].([]ModelField), data["pluralLowName"].(string))
+		if err != nil {
+			return err
+		}
+		if len(unknownTables) > 0 {
+			m.project.logger.Warn("The referenced tables are not generated models, the store tests insert rows with only an id into them", "tables", unknownTables)
+		}
+		data["seedRows"] = seedRows
+		for _, row := range seedRows {
+			data["testHasTime"] = data["testHasTime"].(bool) || slices.ContainsFunc(row.Values, func(value string) bool {
+				return strings.HasPrefix(value, "time.")
+			})
+		}
 		err = m.project.writeStringTemplateToFile(filepath.Join("database", "stores", strings.ToLower(name)+"_store_test.go"), generators.ModelStoreTestTemplate, data)
 		if err != nil {
 			return err
 		}
+
+		err = m.project.writeStringTemplateToFile(filepath.Join("database", "stores", "mock_"+strings.ToLower(name)+"_store.go"), generators.ModelMockStoreTemplate, data)
+		if err != nil {
+			return err
+		}
+
+		err = m.project.addModel(data["pluralLowName"].(string), args)
+		if err != nil {
+			return err
+		}
 		m.project.logger.Info("Generated extra files for model")
 	}
 
 	m.project.logger.Info("Generated model", "modelFile", modelFile)
 	return nil
 }
+
+// addModel saves the table and field specs of a generated model in the project config
+func (p *Project) addModel(table string, specs []string) error {
+	p.Models = slices.DeleteFunc(p.Models, func(model ProjectModel) bool {
+		return model.Table == table
+	})
+	p.Models = append(p.Models, ProjectModel{Table: table, Fields: specs})
+	return p.SaveConfig()
+}
+
+// seedRow is a row the store tests insert into a referenced table, its values are Go expressions
+type seedRow struct {
+	Table   string
+	Columns []string
+	Values  []string
+}
+
+// Query returns the INSERT statement of the row
+func (r seedRow) Query(dialect Dialect) string {
+	placeholders := make([]string, len(r.Columns))
+	for i := range r.Columns {
+		placeholders[i] = dialect.Placeholder(i + 1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.Table, strings.Join(r.Columns, ", "), strings.Join(placeholders, ", "))
+}
+
+// Args returns the values of the row as the arguments of its query
+func (r seedRow) Args() string {
+	return strings.Join(r.Values, ", ")
+}
+
+// seedRows returns the rows the fixtures of the model of table reference, with the rows they reference themselves
+// before them. The rows of the models of the project get the sample values of their fields, the rows of other tables
+// only get an id and their tables are returned as unknown. References of a table to itself need no rows, the fixtures
+// are created in order.
+func (p *Project) seedRows(refFields []ModelField, table string) ([]seedRow, []string, error) {
+	models := make(map[string][]string)
+	for _, model := range p.Models {
+		models[model.Table] = model.Fields
+	}
+
+	var rows []seedRow
+	var unknown []string
+	seen := map[string]bool{table: true}
+	var add func(fields []ModelField) error
+	add = func(fields []ModelField) error {
+		for _, field := range fields {
+			if field.Type != "ref" || seen[field.RefTable] {
+				continue
+			}
+			seen[field.RefTable] = true
+
+			var refFields []ModelField
+			specs, ok := models[field.RefTable]
+			if ok {
+				var err error
+				refFields, err = ParseModelFields(specs)
+				if err != nil {
+					return fmt.Errorf("invalid fields of model %s in %s: %w", field.RefTable, ProjectFile, err)
+				}
+				err = add(refFields)
+				if err != nil {
+					return err
+				}
+			} else {
+				unknown = append(unknown, field.RefTable)
+			}
+
+			for _, n := range fixtureNumbers {
+				row := seedRow{Table: field.RefTable, Columns: []string{"id"}, Values: []string{strconv.Itoa(n)}}
+				for _, refField := range refFields {
+					row.Columns = append(row.Columns, refField.Name)
+					row.Values = append(row.Values, refField.SampleValue(n))
+				}
+				rows = append(rows, row)
+			}
+		}
+		return nil
+	}
+
+	err := add(refFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, unknown, nil
+}
@@ -1,30 +1,41 @@
 package internal
 
 import (
+	"bytes"
 	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/JensvandeWiel/go-bat/pkg"
+	"go/format"
 	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
 	"text/template"
 )
 
 const ProjectFile = "project.json"
 
+// ProjectModel is a table with the field specs of its model, the store tests of models that reference the table insert
+// rows with these fields into it
+type ProjectModel struct {
+	Table  string   `json:"table"`
+	Fields []string `json:"fields"`
+}
+
 type Project struct {
 	logger        *pkg.Logger
-	WorkDir       string     `json:"-"`
-	ProjectName   string     `json:"project_name"`
-	PackageName   string     `json:"package_name"`
-	Force         bool       `json:"-"`
-	Extras        []Extra    `json:"-"`
-	ExtraTypes    ExtraTypes `json:"extras"`
+	WorkDir       string         `json:"-"`
+	ProjectName   string         `json:"project_name"`
+	PackageName   string         `json:"package_name"`
+	Force         bool           `json:"-"`
+	Extras        []Extra        `json:"-"`
+	ExtraTypes    ExtraTypes     `json:"extras"`
+	Models        []ProjectModel `json:"models,omitempty"`
 	funcMap       template.FuncMap
 	tempDir       string
 	noInstallDeps bool
@@ -227,17 +238,31 @@ func (p *Project) writeStringTemplateToFile(filePath string, tmplString string,
 		return err
 	}
 
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return err
+	}
+	content := buf.Bytes()
+	if strings.HasSuffix(filePath, ".go") {
+		content, err = format.Source(content)
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", filePath, err)
+		}
+	}
+
 	file, err := os.OpenFile(path.Join(p.tempDir, filePath), os.O_CREATE|os.O_WRONLY, os.ModePerm)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
 	err = file.Truncate(0)
 	if err != nil {
 		return err
 	}
 
-	err = tmpl.Execute(file, data)
+	_, err = file.Write(content)
 	if err != nil {
 		return err
 	}
@@ -245,6 +270,51 @@ func (p *Project) writeStringTemplateToFile(filePath string, tmplString string,
 	return nil
 }
 
+// registerController adds the controller to the RegisterControllers call in cmd/serve.go
+func (p *Project) registerController(controller string) error {
+	servePath := path.Join(p.tempDir, "cmd", "serve.go")
+	content, err := os.ReadFile(servePath)
+	if err != nil {
+		return err
+	}
+	src := string(content)
+	if strings.Contains(src, controller) {
+		return nil
+	}
+
+	call := "RegisterControllers("
+	start := strings.Index(src, call)
+	if start == -1 {
+		return fmt.Errorf("RegisterControllers call not found in cmd/serve.go")
+	}
+	argsStart := start + len(call)
+	end := -1
+	for i, depth := argsStart, 1; i < len(src) && end == -1; i++ {
+		switch src[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end == -1 {
+		return fmt.Errorf("RegisterControllers call in cmd/serve.go is not closed")
+	}
+
+	args := strings.TrimRight(src[argsStart:end], " \t\n,")
+	if args != "" {
+		args += ", "
+	}
+	formatted, err := format.Source([]byte(src[:argsStart] + args + controller + src[end:]))
+	if err != nil {
+		return fmt.Errorf("failed to format cmd/serve.go: %w", err)
+	}
+	return os.WriteFile(servePath, formatted, 0644)
+}
+
 // moveToProjectDir moves the contents of the tempdir to the project directory
 func (p *Project) moveToProjectDir() error {
 	// Check if the project directory exists already
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/JensvandeWiel/go-bat/internal/templates/generators"
+	"github.com/iancoleman/strcase"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ruleParamRegex matches the table and column of a unique rule
+var ruleParamRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)?$`)
+
+// RequestField is a field of a generated request, parsed from a spec like email:string:required:email. The rules after
+// the type are the rules of bat.Validator.
+type RequestField struct {
+	ModelField
+	Rules []string
+}
+
+// ParseRequestField parses a field spec
+func ParseRequestField(spec string) (RequestField, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 {
+		return RequestField{}, fmt.Errorf("invalid field %q, expected name:type[:rules]", spec)
+	}
+
+	field := RequestField{ModelField: ModelField{Name: parts[0], Type: parts[1]}}
+	if !fieldNameRegex.MatchString(field.Name) {
+		return RequestField{}, fmt.Errorf("invalid field name %q, use lowercase letters, digits and underscores", field.Name)
+	}
+	if strings.HasSuffix(field.Type, "?") {
+		field.Nullable = true
+		field.Type = strings.TrimSuffix(field.Type, "?")
+	}
+	if _, ok := goFieldTypes[field.Type]; !ok || field.Type == "ref" {
+		return RequestField{}, fmt.Errorf("unknown type %q of field %s", field.Type, field.Name)
+	}
+
+	for _, rule := range parts[2:] {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+		case "email":
+			if !field.IsString() {
+				return RequestField{}, fmt.Errorf("email rule of field %s needs a string", field.Name)
+			}
+		case "min", "max":
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				return RequestField{}, fmt.Errorf("%s rule of field %s needs a number, like %s=3", name, field.Name, name)
+			}
+		case "unique":
+			if !ruleParamRegex.MatchString(param) {
+				return RequestField{}, fmt.Errorf("unique rule of field %s needs a table, like unique=users or unique=users.email", field.Name)
+			}
+		default:
+			return RequestField{}, fmt.Errorf("unknown rule %q of field %s", name, field.Name)
+		}
+		field.Rules = append(field.Rules, rule)
+	}
+	return field, nil
+}
+
+// ParseRequestFields parses the field specs, the field names must be unique
+func ParseRequestFields(specs []string) ([]RequestField, error) {
+	fields := make([]RequestField, 0, len(specs))
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		field, err := ParseRequestField(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("field %s is defined twice", field.Name)
+		}
+		seen[field.Name] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Tags returns the struct tags of the field, the form tag lets echo bind forms
+func (f RequestField) Tags() string {
+	tags := fmt.Sprintf("json:\"%s\" form:\"%s\"", f.Name, f.Name)
+	if len(f.Rules) > 0 {
+		tags += fmt.Sprintf(" validate:\"%s\"", strings.Join(f.Rules, ","))
+	}
+	return "`" + tags + "`"
+}
+
+// IsRequired returns whether the field has the required rule
+func (f RequestField) IsRequired() bool {
+	for _, rule := range f.Rules {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+type RequestGenerator struct {
+	project *Project
+}
+
+func (r *RequestGenerator) Generate(name string, args []string, opts GenerateOptions) error {
+	name = strcase.ToCamel(strings.TrimSuffix(strcase.ToCamel(name), "Request"))
+	if name == "" {
+		return fmt.Errorf("invalid request name")
+	}
+
+	fields, err := ParseRequestFields(args)
+	if err != nil {
+		r.project.logger.Error("invalid fields", "error", err)
+		return err
+	}
+
+	requestFile := path.Join("requests", strcase.ToSnake(name)+"_request.go")
+	if _, err := os.Stat(path.Join(r.project.tempDir, requestFile)); err == nil {
+		r.project.logger.Error("request file already exists", "requestFile", requestFile)
+		return fmt.Errorf("request file already exists: %s", requestFile)
+	}
+
+	hasTime := false
+	var requiredFields []RequestField
+	for _, field := range fields {
+		hasTime = hasTime || field.Type == "time"
+		if field.IsRequired() {
+			requiredFields = append(requiredFields, field)
+		}
+	}
+
+	data := map[string]interface{}{
+		"PackageName":    r.project.PackageName,
+		"requestName":    name + "Request",
+		"fields":         fields,
+		"hasTime":        hasTime,
+		"requiredFields": requiredFields,
+	}
+
+	err = os.MkdirAll(path.Join(r.project.tempDir, "requests"), 0755)
+	if err != nil {
+		return err
+	}
+	err = r.project.writeStringTemplateToFile(requestFile, generators.RequestTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	if opts.Extra {
+		r.project.logger.Info("Generating extra files for request")
+		err = r.project.writeStringTemplateToFile(path.Join("requests", strcase.ToSnake(name)+"_request_test.go"), generators.RequestTestTemplate, data)
+		if err != nil {
+			return err
+		}
+
+		r.project.logger.Info("Generated extra files for request")
+	}
+
+	r.project.logger.Info("Generated request", "requestFile", requestFile)
+	return nil
+}
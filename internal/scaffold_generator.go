@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/JensvandeWiel/go-bat/internal/templates/generators"
+	"github.com/iancoleman/strcase"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ScaffoldGenerator generates the model, store, request, resource controller and pages of a resource
+type ScaffoldGenerator struct {
+	project *Project
+}
+
+func (s *ScaffoldGenerator) Generate(name string, args []string, opts GenerateOptions) error {
+	dialect, err := s.project.databaseDialect()
+	if err != nil {
+		s.project.logger.Error("database extra is not in use")
+		return err
+	}
+
+	fields, err := ParseModelFields(args)
+	if err != nil {
+		s.project.logger.Error("invalid fields", "error", err)
+		return err
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("scaffold needs at least one field, like %s title:string", name)
+	}
+
+	data := modelTemplateData(s.project.PackageName, name, fields, dialect)
+	modelName := data["modelName"].(string)
+	pluralName := data["pluralName"].(string)
+	controllerName := pluralName + "Controller"
+	inertiaExtra := s.project.inertiaExtra()
+
+	controllerFile := path.Join("controllers", strcase.ToSnake(pluralName)+"_controller.go")
+	requestFile := path.Join("requests", strcase.ToSnake(modelName)+"_request.go")
+	// Check all files first, so a scaffold is never generated halfway
+	for _, file := range []string{controllerFile, requestFile} {
+		if _, err := os.Stat(path.Join(s.project.tempDir, file)); err == nil {
+			s.project.logger.Error("file already exists", "file", file)
+			return fmt.Errorf("file already exists: %s", file)
+		}
+	}
+
+	// The model generator writes the migration, model, store, mock store and store tests
+	err = (&ModelGenerator{project: s.project}).Generate(name, args, GenerateOptions{Extra: true})
+	if err != nil {
+		return err
+	}
+
+	// invalidField makes the requests of the invalid controller tests fail, they leave it empty or make it too long
+	var invalidField *ModelField
+	invalidByLength, requestHasTime := false, false
+	for _, field := range fields {
+		if invalidField == nil && (field.Required || field.Type == "ref" && !field.Nullable) {
+			invalidField = &field
+		}
+		requestHasTime = requestHasTime || field.Type == "time"
+	}
+	for _, field := range fields {
+		if invalidField == nil && field.Type == "string" && !field.Nullable {
+			invalidField = &field
+			invalidByLength = true
+		}
+	}
+
+	data["controllerName"] = controllerName
+	data["basePath"] = "/" + strcase.ToKebab(pluralName)
+	data["inertia"] = inertiaExtra != nil
+	data["invalidField"] = invalidField
+	data["invalidByLength"] = invalidByLength
+	data["typeName"] = strcase.ToSnake(modelName)
+	data["requestHasTime"] = requestHasTime
+
+	err = os.MkdirAll(path.Join(s.project.tempDir, "requests"), 0755)
+	if err != nil {
+		return err
+	}
+	err = s.project.writeStringTemplateToFile(requestFile, generators.ScaffoldRequestTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	err = s.project.writeStringTemplateToFile(controllerFile, generators.ScaffoldControllerTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	err = s.project.writeStringTemplateToFile(path.Join("controllers", strcase.ToSnake(pluralName)+"_controller_test.go"), generators.ScaffoldControllerTestTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	if inertiaExtra != nil {
+		err = s.generatePages(inertiaExtra, pluralName, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.project.registerController("controllers.New" + controllerName + "()")
+	if err != nil {
+		s.project.logger.Warn("Failed to register the controller, register it in cmd/serve.go", "controller", controllerName, "error", err)
+	}
+
+	s.project.logger.Info("Generated scaffold", "controllerFile", controllerFile, "requestFile", requestFile)
+	return nil
+}
+
+// generatePages writes the type of the model and the pages of the Inertia extra, existing files are not overwritten
+func (s *ScaffoldGenerator) generatePages(inertiaExtra InertiaExtra, pluralName string, data map[string]interface{}) error {
+	files := map[string]string{
+		filepath.Join("frontend", "src", "types", data["typeName"].(string)+".ts"): generators.ScaffoldModelTypeTemplate,
+	}
+	dir := inertiaExtra.ScaffoldPagesDir()
+	entries, err := fs.ReadDir(generators.ScaffoldPages, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		content, err := fs.ReadFile(generators.ScaffoldPages, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		page := strings.TrimSuffix(entry.Name(), ".tmpl")
+		files[filepath.Join("frontend", "src", "Pages", pluralName, page)] = string(content)
+	}
+
+	for file, tmpl := range files {
+		if _, err := os.Stat(path.Join(s.project.tempDir, file)); err == nil {
+			s.project.logger.Info("Page already exists, skipping it", "pageFile", file)
+			continue
+		}
+		err = os.MkdirAll(filepath.Dir(path.Join(s.project.tempDir, file)), 0755)
+		if err != nil {
+			return err
+		}
+		err = s.project.writeStringTemplateToFile(file, tmpl, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
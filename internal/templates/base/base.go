@@ -20,6 +20,9 @@ var GoSumTmpl string
 //go:embed main.go.tmpl
 var MainTmpl string
 
+//go:embed deps.go.tmpl
+var DepsTmpl string
+
 //go:embed .gitignore.tmpl
 var GitIgnoreTmpl string
 
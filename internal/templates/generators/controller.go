@@ -0,0 +1,18 @@
+package generators
+
+import _ "embed"
+
+//go:embed controller/controller.go.tmpl
+var ControllerTemplate string
+
+//go:embed controller/controller_test.go.tmpl
+var ControllerTestTemplate string
+
+//go:embed controller/page.tsx.tmpl
+var ControllerReactPageTemplate string
+
+//go:embed controller/page.svelte.tmpl
+var ControllerSveltePageTemplate string
+
+//go:embed controller/page.vue.tmpl
+var ControllerVuePageTemplate string
@@ -0,0 +1,9 @@
+package generators
+
+import _ "embed"
+
+//go:embed middleware/middleware.go.tmpl
+var MiddlewareTemplate string
+
+//go:embed middleware/middleware_test.go.tmpl
+var MiddlewareTestTemplate string
@@ -13,3 +13,6 @@ var ModelStoreTemplate string
 
 //go:embed model/model_store_test.go.tmpl
 var ModelStoreTestTemplate string
+
+//go:embed model/mock_model_store.go.tmpl
+var ModelMockStoreTemplate string
@@ -0,0 +1,9 @@
+package generators
+
+import _ "embed"
+
+//go:embed request/request.go.tmpl
+var RequestTemplate string
+
+//go:embed request/request_test.go.tmpl
+var RequestTestTemplate string
@@ -0,0 +1,18 @@
+package generators
+
+import "embed"
+
+//go:embed scaffold/controller.go.tmpl
+var ScaffoldControllerTemplate string
+
+//go:embed scaffold/controller_test.go.tmpl
+var ScaffoldControllerTestTemplate string
+
+//go:embed scaffold/request.go.tmpl
+var ScaffoldRequestTemplate string
+
+//go:embed scaffold/model.ts.tmpl
+var ScaffoldModelTypeTemplate string
+
+//go:embed scaffold/pages
+var ScaffoldPages embed.FS
@@ -1,6 +1,7 @@
 import { defineConfig } from 'vite'
 import react from '@vitejs/plugin-react'
 import laravel from 'laravel-vite-plugin'
+import { compression } from 'vite-plugin-compression2'
 
 // https://vitejs.dev/config/
 export default defineConfig({
@@ -9,24 +10,26 @@ export default defineConfig({
       laravel({
         input: 'src/main.tsx',
         refresh: true,
-      })
+      }),
+      // The go server serves the .gz and .br files to clients that accept them
+      compression({ algorithm: 'gzip' }),
+      compression({ algorithm: 'brotliCompress' }),
   ],
   build: {
     manifest: true,
     rollupOptions: {
       input: 'src/main.tsx',
       output: {
-        entryFileNames: 'assets/[name].js',
-        chunkFileNames: 'assets/[name].js',
-        assetFileNames: 'assets/[name].[ext]',
+        // Hashed file names are served with an immutable Cache-Control header
+        entryFileNames: 'assets/[name]-[hash].js',
+        chunkFileNames: 'assets/[name]-[hash].js',
+        assetFileNames: 'assets/[name]-[hash].[ext]',
         manualChunks: undefined, // Disable automatic chunk splitting
       },
     }
   },
   server: {
-    hmr: {
-      host: 'localhost',
-      port: 5000,
-    },
+    // The HMR websocket is proxied through the go server, so it uses the same host and port as the page
+    strictPort: true,
   },
 })
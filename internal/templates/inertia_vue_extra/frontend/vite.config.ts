@@ -0,0 +1,42 @@
+import { defineConfig } from 'vite'
+import vue from '@vitejs/plugin-vue'
+import laravel from 'laravel-vite-plugin'
+import { compression } from 'vite-plugin-compression2'
+
+// https://vitejs.dev/config/
+export default defineConfig({
+  plugins: [
+      laravel({
+        input: 'src/main.ts',
+        refresh: true,
+      }),
+      vue({
+        template: {
+          transformAssetUrls: {
+            base: null,
+            includeAbsolute: false,
+          },
+        },
+      }),
+      // The go server serves the .gz and .br files to clients that accept them
+      compression({ algorithm: 'gzip' }),
+      compression({ algorithm: 'brotliCompress' }),
+  ],
+  build: {
+    manifest: true,
+    rollupOptions: {
+      input: 'src/main.ts',
+      output: {
+        // Hashed file names are served with an immutable Cache-Control header
+        entryFileNames: 'assets/[name]-[hash].js',
+        chunkFileNames: 'assets/[name]-[hash].js',
+        assetFileNames: 'assets/[name]-[hash].[ext]',
+        manualChunks: undefined, // Disable automatic chunk splitting
+      },
+    }
+  },
+  server: {
+    // The HMR websocket is proxied through the go server, so it uses the same host and port as the page
+    strictPort: true,
+  },
+})
@@ -0,0 +1,11 @@
+package inertia_vue_extra
+
+import (
+	"embed"
+)
+
+//go:embed controllers/inertia_controller.go.tmpl
+var ControllersInertiaController string
+
+//go:embed frontend
+var Frontend embed.FS
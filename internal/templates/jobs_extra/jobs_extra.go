@@ -0,0 +1,15 @@
+package jobs_extra
+
+import _ "embed"
+
+//go:embed cmd/worker.go.tmpl
+var WorkerTemplate string
+
+//go:embed jobs/jobs.go.tmpl
+var JobsTemplate string
+
+//go:embed jobs/jobs_test.go.tmpl
+var JobsTestTemplate string
+
+//go:embed tasks/tasks.go.tmpl
+var TasksTemplate string
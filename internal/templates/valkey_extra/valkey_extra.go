@@ -0,0 +1,6 @@
+package valkey_extra
+
+import _ "embed"
+
+//go:embed cache/connect.go.tmpl
+var CacheConnectTemplate string
@@ -0,0 +1,68 @@
+package internal
+
+import "github.com/JensvandeWiel/go-bat/internal/templates/valkey_extra"
+
+type ValkeyExtra struct {
+}
+
+func NewValkeyExtra() *ValkeyExtra {
+	return &ValkeyExtra{}
+}
+
+func (v *ValkeyExtra) Generate(project *Project) error {
+	err := project.writeStringTemplateToFile("cache/connect.go", valkey_extra.CacheConnectTemplate, project)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (v *ValkeyExtra) ModEntries() []string {
+	return []string{
+		"github.com/valkey-io/valkey-go v1.0.54",
+	}
+}
+
+func (v *ValkeyExtra) GitIgnoreEntries() []string {
+	return []string{}
+}
+
+func (v *ValkeyExtra) GetExtraPersistentFlags() []string {
+	return []string{
+		"rootCmd.PersistentFlags().String(\"CACHE_HOST\", \"localhost\", \"the cache host\")",
+		"rootCmd.PersistentFlags().Int(\"CACHE_PORT\", 6379, \"the cache port\")",
+		"viper.BindPFlag(\"CACHE_HOST\", rootCmd.PersistentFlags().Lookup(\"CACHE_HOST\"))",
+		"viper.BindPFlag(\"CACHE_PORT\", rootCmd.PersistentFlags().Lookup(\"CACHE_PORT\"))",
+	}
+}
+
+func (v *ValkeyExtra) ExtraType() ExtraType {
+	return Valkey
+}
+
+func (v *ValkeyExtra) DisallowedExtraTypes() []ExtraType {
+	return []ExtraType{}
+}
+
+func (v *ValkeyExtra) ComposerServices() []string {
+	return []string{`  valkey:
+    image: valkey/valkey:8
+    ports:
+      - "6379:6379"
+    volumes:
+      - valkey_data:/data
+`}
+}
+
+func (v *ValkeyExtra) ComposerVolumes() []string {
+	return []string{`  valkey_data:`}
+}
+
+func (v *ValkeyExtra) RequiredExtraTypes() ExtraTypes {
+	return ExtraTypes{}
+}
+
+func (v *ValkeyExtra) OneOfExtraTypes() ExtraTypes {
+	return ExtraTypes{}
+}
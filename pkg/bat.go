@@ -1,14 +1,27 @@
 package pkg
 
 import (
+	"context"
+	"errors"
 	"github.com/labstack/echo/v4"
 	slogecho "github.com/samber/slog-echo"
 	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
 	"reflect"
+	"syscall"
+	"time"
 )
 
 var skipPaths = []string{"/src", "/@*", "/node_modules", "/build/", "/@vite", "/@react-refresh"}
 
+// DefaultShutdownTimeout is the default time the server and the shutdown hooks get to shut down gracefully
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ShutdownHook is a function that is called when the server shuts down
+type ShutdownHook func(ctx context.Context) error
+
 type BatOption func(*Bat) error
 
 type Bat struct {
@@ -17,6 +30,9 @@ type Bat struct {
 	extensions map[reflect.Type]interface{}
 	// SkipPaths is a list of paths that should not be logged
 	SkipPaths []string
+	// ShutdownTimeout is the time the server and the shutdown hooks get to shut down gracefully
+	ShutdownTimeout time.Duration
+	shutdownHooks   []ShutdownHook
 }
 
 func (b *Bat) RegisterControllers(controllers ...Controller) error {
@@ -29,14 +45,20 @@ func (b *Bat) RegisterControllers(controllers ...Controller) error {
 }
 func NewBat(logger *Logger, extensions ...Extension) (*Bat, error) {
 	bat := &Bat{
-		Logger:     logger,
-		Echo:       echo.New(),
-		extensions: make(map[reflect.Type]interface{}),
-		SkipPaths:  skipPaths,
+		Logger:          logger,
+		Echo:            echo.New(),
+		extensions:      make(map[reflect.Type]interface{}),
+		SkipPaths:       skipPaths,
+		ShutdownTimeout: DefaultShutdownTimeout,
 	}
 
+	// Every middleware and handler after this one gets a BatContext
+	bat.Use(bat.contextMiddleware)
+
 	err := bat.registerExtensions(extensions...)
 	if err != nil {
+		// Let the extensions that were registered clean up, e.g. stop child processes
+		bat.runShutdownHooks()
 		return nil, err
 	}
 
@@ -48,12 +70,63 @@ func NewBat(logger *Logger, extensions ...Extension) (*Bat, error) {
 	return bat, nil
 }
 
+// OnShutdown registers a hook that is called when the server shuts down, hooks are called in reverse order of registration
+func (b *Bat) OnShutdown(hook ShutdownHook) {
+	b.shutdownHooks = append(b.shutdownHooks, hook)
+}
+
+// Start starts the server and blocks until it is stopped, on SIGINT or SIGTERM the server is shut down gracefully
 func (b *Bat) Start(addr string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	b.Logger.Info("Starting server", slog.String("address", addr))
-	err := b.Echo.Start(addr)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- b.Echo.Start(addr)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			b.Logger.Error("Failed to start server", slog.String("error", err.Error()))
+			b.runShutdownHooks()
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+		b.Logger.Info("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), b.ShutdownTimeout)
+		defer cancel()
+		return b.Shutdown(shutdownCtx)
+	}
+}
+
+// Shutdown gracefully shuts down the server and runs the shutdown hooks
+func (b *Bat) Shutdown(ctx context.Context) error {
+	err := b.Echo.Shutdown(ctx)
 	if err != nil {
-		b.Logger.Error("Failed to start server", slog.String("error", err.Error()))
-		return err
+		b.Logger.Error("Failed to shut down server", slog.String("error", err.Error()))
 	}
-	return nil
+	return errors.Join(err, b.callShutdownHooks(ctx))
+}
+
+// runShutdownHooks runs the shutdown hooks with the default shutdown timeout
+func (b *Bat) runShutdownHooks() {
+	ctx, cancel := context.WithTimeout(context.Background(), b.ShutdownTimeout)
+	defer cancel()
+	_ = b.callShutdownHooks(ctx)
+}
+
+// callShutdownHooks calls the shutdown hooks in reverse order of registration
+func (b *Bat) callShutdownHooks(ctx context.Context) error {
+	var errs []error
+	for i := len(b.shutdownHooks) - 1; i >= 0; i-- {
+		err := b.shutdownHooks[i](ctx)
+		if err != nil {
+			b.Logger.Error("Shutdown hook failed", slog.String("error", err.Error()))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
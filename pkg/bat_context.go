@@ -1,16 +1,117 @@
 package pkg
 
 import (
+	"context"
+	"errors"
 	"github.com/labstack/echo/v4"
+	"github.com/romsar/gonertia/v2"
 	"net/http"
 )
 
+var (
+	FlashExtensionMissingError = errors.New("flashing validation errors of inertia requests needs the FlashExtension")
+	// ValidationHandledError is returned by BindAndValidate when the request is invalid and the errors are answered
+	ValidationHandledError = errors.New("validation failed, the errors are answered")
+)
+
+// ErrorFlasherKey is the key of the ErrorFlasher in the echo context, the FlashExtension sets itself
+const ErrorFlasherKey = "error_flasher"
+
+// ErrorFlasher flashes validation errors, so the next request of the session gets them
+type ErrorFlasher interface {
+	FlashErrors(ctx context.Context, errors gonertia.ValidationErrors) error
+}
+
+// BatContext is the context of the handlers of a Bat, every handler gets one and can use it with ctx.(*BatContext)
 type BatContext struct {
 	echo.Context
+	bat *Bat
 }
 
 func (b *Bat) NewContext(req *http.Request, res http.ResponseWriter) echo.Context {
 	return &BatContext{
 		Context: b.Echo.NewContext(req, res),
+		bat:     b,
+	}
+}
+
+// contextMiddleware passes a BatContext to the next handlers
+func (b *Bat) contextMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, ok := c.(*BatContext); ok {
+			return next(c)
+		}
+		return next(&BatContext{Context: c, bat: b})
+	}
+}
+
+// Bat returns the app of the context
+func (c *BatContext) Bat() *Bat {
+	return c.bat
+}
+
+// BindAndValidate binds the request into v and validates it with the validator, or with its Validate method when v is
+// Validatable. A nil validator validates without a database. When v is invalid the request is answered with
+// ValidationFailed and ValidationHandledError is returned, which the handler returns as nil:
+//
+//	err := ctx.(*bat.BatContext).BindAndValidate(c.validator, &req)
+//	if errors.Is(err, bat.ValidationHandledError) {
+//		return nil
+//	}
+//	if err != nil {
+//		return err
+//	}
+func (c *BatContext) BindAndValidate(validator *Validator, v any) error {
+	err := c.Bind(v)
+	if err != nil {
+		return err
+	}
+
+	if validator == nil {
+		validator, err = NewValidator()
+		if err != nil {
+			return err
+		}
+	}
+	var errs gonertia.ValidationErrors
+	if validatable, ok := v.(Validatable); ok {
+		errs, err = validatable.Validate(c.Request().Context(), validator)
+	} else {
+		errs, err = validator.Validate(c.Request().Context(), v)
+	}
+	if err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	err = c.ValidationFailed(errs)
+	if err != nil {
+		return err
+	}
+	return ValidationHandledError
+}
+
+// ValidationFailed answers the request with invalid input. Inertia requests get the errors flashed with the ErrorFlasher
+// of the context and are redirected back with 303, other requests get a 422 with the errors as JSON.
+func (c *BatContext) ValidationFailed(errs gonertia.ValidationErrors) error {
+	if !gonertia.IsInertiaRequest(c.Request()) {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]any{"errors": errs})
+	}
+
+	flasher, ok := c.Get(ErrorFlasherKey).(ErrorFlasher)
+	if !ok {
+		return FlashExtensionMissingError
+	}
+	err := flasher.FlashErrors(c.Request().Context(), errs)
+	if err != nil {
+		return err
+	}
+
+	back := c.Request().Referer()
+	if back == "" {
+		back = "/"
 	}
+	return c.Redirect(http.StatusSeeOther, back)
 }
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"github.com/valkey-io/valkey-go"
+	"time"
+)
+
+var CacheMissError = errors.New("cache miss")
+
+// CacheBackend is the storage used by the CacheExtension
+type CacheBackend interface {
+	// Get returns the value of the key, it returns CacheMissError if the key does not exist
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set sets the value of the key, a ttl of 0 means the key does not expire
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete deletes the keys
+	Delete(ctx context.Context, keys ...string) error
+	// AddTags adds the key to the tags, so it is deleted when one of the tags is invalidated
+	AddTags(ctx context.Context, key string, ttl time.Duration, tags ...string) error
+	// InvalidateTags deletes all keys of the tags and the tags themselves
+	InvalidateTags(ctx context.Context, tags ...string) error
+	// Locker prevents that multiple processes compute the same value
+	Locker
+}
+
+// ValkeyCacheBackend is a CacheBackend that stores the values in valkey
+type ValkeyCacheBackend struct {
+	*ValkeyLocker
+	client valkey.Client
+	// clientSideCacheTTL is the ttl of values in the client side cache, client side caching is disabled if it is 0
+	clientSideCacheTTL time.Duration
+}
+
+// NewValkeyCacheBackend creates a new valkey cache backend, when clientSideCacheTTL is greater than 0 reads are cached in
+// memory using valkey's client side caching, valkey invalidates them when the key changes
+func NewValkeyCacheBackend(client valkey.Client, clientSideCacheTTL time.Duration) *ValkeyCacheBackend {
+	return &ValkeyCacheBackend{
+		ValkeyLocker:       NewValkeyLocker(client),
+		client:             client,
+		clientSideCacheTTL: clientSideCacheTTL,
+	}
+}
+
+// Get returns the value of the key
+func (v *ValkeyCacheBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	var res valkey.ValkeyResult
+	if v.clientSideCacheTTL > 0 {
+		res = v.client.DoCache(ctx, v.client.B().Get().Key(key).Cache(), v.clientSideCacheTTL)
+	} else {
+		res = v.client.Do(ctx, v.client.B().Get().Key(key).Build())
+	}
+
+	b, err := res.AsBytes()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			return nil, CacheMissError
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// Set sets the value of the key
+func (v *ValkeyCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl > 0 {
+		return v.client.Do(ctx, v.client.B().Set().Key(key).Value(valkey.BinaryString(value)).Px(ttl).Build()).Error()
+	}
+	return v.client.Do(ctx, v.client.B().Set().Key(key).Value(valkey.BinaryString(value)).Build()).Error()
+}
+
+// Delete deletes the keys
+func (v *ValkeyCacheBackend) Delete(ctx context.Context, keys ...string) error {
+	// The keys are deleted one by one, because valkey-go does not allow multi key commands over different slots
+	cmds := make(valkey.Commands, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, v.client.B().Del().Key(key).Build())
+	}
+	for _, res := range v.client.DoMulti(ctx, cmds...) {
+		if err := res.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTags adds the key to the tag sets, the tag sets live at least as long as the key
+func (v *ValkeyCacheBackend) AddTags(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	cmds := make(valkey.Commands, 0, len(tags)*3)
+	for _, tag := range tags {
+		cmds = append(cmds, v.client.B().Sadd().Key(tag).Member(key).Build())
+		if ttl > 0 {
+			ms := ttl.Milliseconds()
+			cmds = append(cmds,
+				v.client.B().Pexpire().Key(tag).Milliseconds(ms).Nx().Build(),
+				v.client.B().Pexpire().Key(tag).Milliseconds(ms).Gt().Build())
+		} else {
+			cmds = append(cmds, v.client.B().Persist().Key(tag).Build())
+		}
+	}
+
+	for _, res := range v.client.DoMulti(ctx, cmds...) {
+		if err := res.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTags deletes all keys of the tags and the tags themselves
+func (v *ValkeyCacheBackend) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		keys, err := v.client.Do(ctx, v.client.B().Smembers().Key(tag).Build()).AsStrSlice()
+		if err != nil {
+			return err
+		}
+		err = v.Delete(ctx, append(keys, tag)...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"golang.org/x/sync/singleflight"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+const DefaultCacheKeyPrefix = "cache:"
+const DefaultCacheLockTimeout = 10 * time.Second
+
+// cacheLockPollInterval is the time to wait between checks while another process computes a value
+const cacheLockPollInterval = 50 * time.Millisecond
+
+// CacheExtension is an extension that provides caching on top of valkey, or on top of a custom CacheBackend
+type CacheExtension struct {
+	backend            CacheBackend
+	logger             *Logger
+	keyPrefix          string
+	clientSideCacheTTL time.Duration
+	lockTimeout        time.Duration
+	group              singleflight.Group
+}
+
+// CacheExtensionOption is a function that modifies the CacheExtension
+type CacheExtensionOption func(*CacheExtension) error
+
+// WithCacheBackend sets the backend of the cache, by default the client of the ValkeyExtension is used
+func WithCacheBackend(backend CacheBackend) CacheExtensionOption {
+	return func(c *CacheExtension) error {
+		c.backend = backend
+		return nil
+	}
+}
+
+// WithCacheKeyPrefix sets the prefix of all cache keys
+func WithCacheKeyPrefix(prefix string) CacheExtensionOption {
+	return func(c *CacheExtension) error {
+		c.keyPrefix = prefix
+		return nil
+	}
+}
+
+// WithClientSideCaching enables valkey client side caching, values are kept in memory for at most ttl and are
+// invalidated by valkey when they change. It only applies to the default valkey backend.
+func WithClientSideCaching(ttl time.Duration) CacheExtensionOption {
+	return func(c *CacheExtension) error {
+		c.clientSideCacheTTL = ttl
+		return nil
+	}
+}
+
+// WithCacheLockTimeout sets how long the lock that prevents concurrent computation of the same key is held at most
+func WithCacheLockTimeout(timeout time.Duration) CacheExtensionOption {
+	return func(c *CacheExtension) error {
+		c.lockTimeout = timeout
+		return nil
+	}
+}
+
+// NewCacheExtension creates a new cache extension
+func NewCacheExtension(opts ...CacheExtensionOption) (*CacheExtension, error) {
+	ext := &CacheExtension{
+		keyPrefix:   DefaultCacheKeyPrefix,
+		lockTimeout: DefaultCacheLockTimeout,
+	}
+
+	for _, opt := range opts {
+		err := opt(ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ext, nil
+}
+
+// Register registers the cache extension
+func (c *CacheExtension) Register(app *Bat) error {
+	c.logger = &Logger{app.Logger.With("module", "cache_extension")}
+	if c.backend == nil {
+		c.backend = NewValkeyCacheBackend(GetExtension[*ValkeyExtension](app).GetClient(), c.clientSideCacheTTL)
+	}
+	return nil
+}
+
+// Requirements returns the requirements for the cache extension
+func (c *CacheExtension) Requirements() []reflect.Type {
+	if c.backend == nil {
+		return []reflect.Type{
+			reflect.TypeOf(ValkeyExtension{}),
+		}
+	}
+	return []reflect.Type{}
+}
+
+// Backend returns the backend of the cache
+func (c *CacheExtension) Backend() CacheBackend {
+	return c.backend
+}
+
+func (c *CacheExtension) key(key string) string {
+	return c.keyPrefix + key
+}
+
+func (c *CacheExtension) tagKeys(tags []string) []string {
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = c.keyPrefix + "tag:" + tag
+	}
+	return keys
+}
+
+func (c *CacheExtension) lockKey(key string) string {
+	return c.keyPrefix + "lock:" + key
+}
+
+// Get returns the raw value of the key, it returns CacheMissError if the key does not exist
+func (c *CacheExtension) Get(ctx context.Context, key string) ([]byte, error) {
+	return c.backend.Get(ctx, c.key(key))
+}
+
+// Set sets the raw value of the key, a ttl of 0 means the key does not expire. The key is deleted when one of the
+// tags is invalidated.
+func (c *CacheExtension) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	err := c.backend.Set(ctx, c.key(key), value, ttl)
+	if err != nil {
+		return err
+	}
+	if len(tags) > 0 {
+		return c.backend.AddTags(ctx, c.key(key), ttl, c.tagKeys(tags)...)
+	}
+	return nil
+}
+
+// Delete deletes the keys
+func (c *CacheExtension) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.key(key)
+	}
+	return c.backend.Delete(ctx, prefixed...)
+}
+
+// InvalidateTags deletes all keys that were set with one of the tags
+func (c *CacheExtension) InvalidateTags(ctx context.Context, tags ...string) error {
+	c.logger.Debug("Invalidating cache tags", slog.Any("tags", tags))
+	return c.backend.InvalidateTags(ctx, c.tagKeys(tags)...)
+}
+
+// Remember returns the raw value of the key, if it does not exist it is computed with fn and stored. Concurrent calls
+// for the same key are deduplicated in this process, and a lock makes sure only one process computes the value.
+// fn runs under a context that is not cancelled with ctx, because its result is shared by all callers of the key.
+func (c *CacheExtension) Remember(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) ([]byte, error), tags ...string) ([]byte, error) {
+	value, err := c.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, CacheMissError) {
+		return nil, err
+	}
+
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		return c.computeLocked(context.WithoutCancel(ctx), key, ttl, fn, tags)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	}
+}
+
+// computeLocked computes the value while holding the lock of the key, if the lock is held by another process it waits
+// for that process to store the value
+func (c *CacheExtension) computeLocked(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) ([]byte, error), tags []string) ([]byte, error) {
+	deadline := time.Now().Add(c.lockTimeout)
+	for {
+		unlock, acquired, err := c.backend.Lock(ctx, c.lockKey(key), c.lockTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		if acquired {
+			defer func() {
+				if err := unlock(context.Background()); err != nil {
+					c.logger.Error("Failed to release cache lock", slog.String("key", key), slog.Any("err", err))
+				}
+			}()
+			// The value could have been stored while waiting for the lock
+			value, err := c.Get(ctx, key)
+			if err == nil {
+				return value, nil
+			}
+			return c.compute(ctx, key, ttl, fn, tags)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(cacheLockPollInterval):
+		}
+
+		value, err := c.Get(ctx, key)
+		if err == nil {
+			return value, nil
+		}
+		if !errors.Is(err, CacheMissError) {
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			c.logger.Warn("Timed out waiting for cache lock, computing without lock", slog.String("key", key))
+			return c.compute(ctx, key, ttl, fn, tags)
+		}
+	}
+}
+
+func (c *CacheExtension) compute(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) ([]byte, error), tags []string) ([]byte, error) {
+	value, err := fn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = c.Set(ctx, key, value, ttl, tags...)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// CacheGet returns the value of the key decoded into T, it returns CacheMissError if the key does not exist
+func CacheGet[T any](ctx context.Context, c *CacheExtension, key string) (T, error) {
+	var value T
+	b, err := c.Get(ctx, key)
+	if err != nil {
+		return value, err
+	}
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&value)
+	return value, err
+}
+
+// CacheSet encodes the value and stores it under the key, a ttl of 0 means the key does not expire
+func CacheSet[T any](ctx context.Context, c *CacheExtension, key string, value T, ttl time.Duration, tags ...string) error {
+	b, err := encodeCacheValue(value)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, b, ttl, tags...)
+}
+
+// CacheRemember returns the value of the key decoded into T, if it does not exist it is computed with fn and stored,
+// see CacheExtension.Remember
+func CacheRemember[T any](ctx context.Context, c *CacheExtension, key string, ttl time.Duration, fn func(ctx context.Context) (T, error), tags ...string) (T, error) {
+	var value T
+	b, err := c.Remember(ctx, key, ttl, func(ctx context.Context) ([]byte, error) {
+		v, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return encodeCacheValue(v)
+	}, tags...)
+	if err != nil {
+		return value, err
+	}
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&value)
+	return value, err
+}
+
+func encodeCacheValue[T any](value T) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,179 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newMemoryCacheExtension(t *testing.T) *CacheExtension {
+	t.Helper()
+	ext, err := NewCacheExtension(WithCacheBackend(NewMemoryCacheBackend()))
+	if err != nil {
+		t.Fatalf("NewCacheExtension returned %v", err)
+	}
+	if _, err := NewBat(&Logger{slog.New(slog.NewTextHandler(io.Discard, nil))}, ext); err != nil {
+		t.Fatalf("NewBat returned %v", err)
+	}
+	return ext
+}
+
+func TestRememberReturnsTheCachedValue(t *testing.T) {
+	c := newMemoryCacheExtension(t)
+	ctx := context.Background()
+	if err := c.Set(ctx, "key", []byte("cached"), time.Minute); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	value, err := c.Remember(ctx, "key", time.Minute, func(ctx context.Context) ([]byte, error) {
+		t.Error("the loader ran for a cached key")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Remember returned %v", err)
+	}
+	if string(value) != "cached" {
+		t.Fatalf("Remember returned %q, want %q", value, "cached")
+	}
+}
+
+func TestRememberStoresTheLoadedValue(t *testing.T) {
+	c := newMemoryCacheExtension(t)
+	ctx := context.Background()
+
+	value, err := c.Remember(ctx, "key", time.Minute, func(ctx context.Context) ([]byte, error) {
+		return []byte("loaded"), nil
+	})
+	if err != nil {
+		t.Fatalf("Remember returned %v", err)
+	}
+	if string(value) != "loaded" {
+		t.Fatalf("Remember returned %q, want %q", value, "loaded")
+	}
+
+	stored, err := c.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("Get returned %v", err)
+	}
+	if string(stored) != "loaded" {
+		t.Fatalf("Get returned %q, want %q", stored, "loaded")
+	}
+}
+
+func TestRememberDoesNotStoreLoaderErrors(t *testing.T) {
+	c := newMemoryCacheExtension(t)
+	ctx := context.Background()
+	loaderErr := errors.New("loader failed")
+
+	_, err := c.Remember(ctx, "key", time.Minute, func(ctx context.Context) ([]byte, error) {
+		return nil, loaderErr
+	})
+	if !errors.Is(err, loaderErr) {
+		t.Fatalf("Remember returned %v, want %v", err, loaderErr)
+	}
+	if _, err := c.Get(ctx, "key"); !errors.Is(err, CacheMissError) {
+		t.Fatalf("Get returned %v, want %v", err, CacheMissError)
+	}
+}
+
+func TestRememberRunsTheLoaderOnceForConcurrentCallers(t *testing.T) {
+	c := newMemoryCacheExtension(t)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("loaded"), nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	values := make([][]byte, callers)
+	errs := make([]error, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values[i], errs[i] = c.Remember(ctx, "key", time.Minute, loader)
+		}()
+	}
+
+	// Give every caller the time to join the running loader before it returns
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("the loader ran %d times, want 1", n)
+	}
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("Remember returned %v", errs[i])
+		}
+		if string(values[i]) != "loaded" {
+			t.Fatalf("Remember returned %q, want %q", values[i], "loaded")
+		}
+	}
+}
+
+func TestRememberKeepsLoadingWhenACallerIsCancelled(t *testing.T) {
+	c := newMemoryCacheExtension(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(ctx context.Context) ([]byte, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []byte("loaded"), nil
+	}
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error, 1)
+	go func() {
+		_, err := c.Remember(cancelledCtx, "key", time.Minute, loader)
+		cancelledErr <- err
+	}()
+	<-started
+
+	type result struct {
+		value []byte
+		err   error
+	}
+	other := make(chan result, 1)
+	go func() {
+		value, err := c.Remember(context.Background(), "key", time.Minute, loader)
+		other <- result{value, err}
+	}()
+
+	// The cancelled caller returns right away while the loader keeps running for the other caller
+	cancel()
+	select {
+	case err := <-cancelledErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Remember of the cancelled caller returned %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("Remember of the cancelled caller did not return")
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	res := <-other
+	if res.err != nil {
+		t.Fatalf("Remember returned %v", res.err)
+	}
+	if string(res.value) != "loaded" {
+		t.Fatalf("Remember returned %q, want %q", res.value, "loaded")
+	}
+}
@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryCacheBackend is a CacheBackend that stores the values in memory, it is meant for tests and single instance apps
+type MemoryCacheBackend struct {
+	*MemoryLocker
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	tags    map[string]map[string]struct{}
+}
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e memoryCacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// NewMemoryCacheBackend creates a new in-memory cache backend
+func NewMemoryCacheBackend() *MemoryCacheBackend {
+	return &MemoryCacheBackend{
+		MemoryLocker: NewMemoryLocker(),
+		entries:      make(map[string]memoryCacheEntry),
+		tags:         make(map[string]map[string]struct{}),
+	}
+}
+
+func (m *MemoryCacheBackend) expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// Get returns the value of the key
+func (m *MemoryCacheBackend) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, CacheMissError
+	}
+	if entry.expired(time.Now()) {
+		delete(m.entries, key)
+		return nil, CacheMissError
+	}
+
+	value := make([]byte, len(entry.value))
+	copy(value, entry.value)
+	return value, nil
+}
+
+// Set sets the value of the key
+func (m *MemoryCacheBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := make([]byte, len(value))
+	copy(stored, value)
+	m.entries[key] = memoryCacheEntry{value: stored, expiresAt: m.expiresAt(ttl)}
+	return nil
+}
+
+// Delete deletes the keys
+func (m *MemoryCacheBackend) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// AddTags adds the key to the tags
+func (m *MemoryCacheBackend) AddTags(_ context.Context, key string, _ time.Duration, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tag := range tags {
+		if _, ok := m.tags[tag]; !ok {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+	return nil
+}
+
+// InvalidateTags deletes all keys of the tags and the tags themselves
+func (m *MemoryCacheBackend) InvalidateTags(_ context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			delete(m.entries, key)
+		}
+		delete(m.tags, tag)
+	}
+	return nil
+}
@@ -1,27 +1,363 @@
 package pkg
 
 import (
+	"context"
+	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
 	"reflect"
+	"sync/atomic"
+	"time"
 )
 
+const DefaultDatabaseMaxOpenConns = 25
+const DefaultDatabaseMaxIdleConns = 25
+const DefaultDatabaseConnMaxLifetime = 30 * time.Minute
+const DefaultDatabaseConnMaxIdleTime = 5 * time.Minute
+const DefaultDatabaseSlowQueryThreshold = 500 * time.Millisecond
+const DefaultDatabaseReplicaHealthCheckInterval = 5 * time.Second
+
+// dbTxKey is the context key of the transaction of a context
+type dbTxKey struct{}
+
+// dbTx is a transaction in a context, depth is the number of WithTx calls it is nested in
+type dbTx struct {
+	tx    *sqlx.Tx
+	depth int
+}
+
+// DatabaseExtension is an extension that configures the connection pool of the database and runs transactions
 type DatabaseExtension struct {
-	db *sqlx.DB
+	db                  *sqlx.DB
+	logger              *Logger
+	pool                []func(db *sqlx.DB)
+	queryLogging        bool
+	slowQueryThreshold  time.Duration
+	requestTransactions bool
+	replicas            []*dbReplica
+	nextReplica         atomic.Uint64
+	healthCheckInterval time.Duration
+	stickyAfterWrite    bool
+}
+
+// DatabaseExtensionOption is a function that modifies the DatabaseExtension
+type DatabaseExtensionOption func(*DatabaseExtension) error
+
+// WithDatabaseMaxOpenConns sets the maximum number of open connections, 0 means unlimited
+func WithDatabaseMaxOpenConns(n int) DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.pool = append(d.pool, func(db *sqlx.DB) { db.SetMaxOpenConns(n) })
+		return nil
+	}
+}
+
+// WithDatabaseMaxIdleConns sets the maximum number of idle connections
+func WithDatabaseMaxIdleConns(n int) DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.pool = append(d.pool, func(db *sqlx.DB) { db.SetMaxIdleConns(n) })
+		return nil
+	}
+}
+
+// WithDatabaseConnMaxLifetime sets how long a connection may be reused, 0 means forever
+func WithDatabaseConnMaxLifetime(lifetime time.Duration) DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.pool = append(d.pool, func(db *sqlx.DB) { db.SetConnMaxLifetime(lifetime) })
+		return nil
+	}
+}
+
+// WithDatabaseConnMaxIdleTime sets how long a connection may be idle before it is closed, 0 means forever
+func WithDatabaseConnMaxIdleTime(idleTime time.Duration) DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.pool = append(d.pool, func(db *sqlx.DB) { db.SetConnMaxIdleTime(idleTime) })
+		return nil
+	}
+}
+
+// WithDatabaseQueryLogging enables or disables logging the queries run through Conn, queries are logged on debug level
+func WithDatabaseQueryLogging(enabled bool) DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.queryLogging = enabled
+		return nil
+	}
+}
+
+// WithDatabaseSlowQueryThreshold sets the duration after which a query is logged as slow on warn level, 0 disables it
+func WithDatabaseSlowQueryThreshold(threshold time.Duration) DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.slowQueryThreshold = threshold
+		return nil
+	}
+}
+
+// WithRequestTransactions runs every request that is not a GET, HEAD or OPTIONS request in a transaction, see
+// TransactionMiddleware
+func WithRequestTransactions() DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.requestTransactions = true
+		return nil
+	}
+}
+
+// WithDatabaseReplicas sets the read replicas of the database, read queries outside transactions are sent to a healthy
+// replica and all other queries to the primary database
+func WithDatabaseReplicas(replicas ...*sqlx.DB) DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		for i, db := range replicas {
+			r := &dbReplica{db: db, index: i}
+			r.healthy.Store(true)
+			d.replicas = append(d.replicas, r)
+		}
+		return nil
+	}
+}
+
+// WithDatabaseReplicaHealthCheckInterval sets the interval in which the replicas are pinged, an unhealthy replica gets
+// no queries until it answers again
+func WithDatabaseReplicaHealthCheckInterval(interval time.Duration) DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.healthCheckInterval = interval
+		return nil
+	}
+}
+
+// WithDatabaseStickyAfterWrite sends all queries of a request to the primary database after the request wrote to it,
+// so the request reads its own writes even when the replicas lag behind
+func WithDatabaseStickyAfterWrite() DatabaseExtensionOption {
+	return func(d *DatabaseExtension) error {
+		d.stickyAfterWrite = true
+		return nil
+	}
+}
+
+// NewDatabaseExtension creates a new database extension, the connection pool of db is left as it is
+func NewDatabaseExtension(db *sqlx.DB) *DatabaseExtension {
+	return &DatabaseExtension{
+		db:                  db,
+		queryLogging:        true,
+		slowQueryThreshold:  DefaultDatabaseSlowQueryThreshold,
+		healthCheckInterval: DefaultDatabaseReplicaHealthCheckInterval,
+	}
 }
 
+// NewDatabaseExtensionWithOptions creates a new database extension with options, the connection pool of db is only
+// changed by the pool options that are passed
+func NewDatabaseExtensionWithOptions(db *sqlx.DB, opts ...DatabaseExtensionOption) (*DatabaseExtension, error) {
+	ext := NewDatabaseExtension(db)
+
+	for _, opt := range opts {
+		err := opt(ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ext, nil
+}
+
+// Register registers the database extension and applies the connection pool settings of its options
 func (d *DatabaseExtension) Register(app *Bat) error {
-	app.Logger.Info("Registering DatabaseExtension")
+	d.logger = &Logger{app.Logger.With("module", "database_extension")}
+
+	for _, configure := range d.pool {
+		configure(d.db)
+		for _, r := range d.replicas {
+			configure(r.db)
+		}
+	}
+
+	if len(d.replicas) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		go d.checkReplicas(ctx)
+		app.OnShutdown(func(context.Context) error {
+			cancel()
+			return nil
+		})
+
+		if d.stickyAfterWrite {
+			app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), dbStickyKey{}, &dbSticky{})))
+					return next(c)
+				}
+			})
+		}
+	}
+
+	if d.requestTransactions {
+		middleware := d.TransactionMiddleware()
+		app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			withTx := middleware(next)
+			return func(c echo.Context) error {
+				switch c.Request().Method {
+				case http.MethodGet, http.MethodHead, http.MethodOptions:
+					// Reads do not need a transaction, and long-lived streams would hold a connection
+					return next(c)
+				}
+				return withTx(c)
+			}
+		})
+	}
 	return nil
 }
 
+// Requirements returns the requirements for the database extension
 func (d *DatabaseExtension) Requirements() []reflect.Type {
 	return []reflect.Type{}
 }
 
+// GetDB returns the primary database
 func (d *DatabaseExtension) GetDB() *sqlx.DB {
 	return d.db
 }
 
-func NewDatabaseExtension(db *sqlx.DB) *DatabaseExtension {
-	return &DatabaseExtension{db: db}
+// Conn returns the transaction of ctx, or the database when ctx has no transaction. Stores should use it for all
+// queries, so they take part in the transaction started by WithTx or the TransactionMiddleware. With replicas, read
+// queries outside a transaction are routed to a replica.
+func (d *DatabaseExtension) Conn(ctx context.Context) Queryer {
+	var q Queryer = d.db
+	if t, ok := ctx.Value(dbTxKey{}).(*dbTx); ok {
+		q = t.tx
+	} else if len(d.replicas) > 0 {
+		q = &routingQueryer{Queryer: d.db, ext: d, ctx: ctx}
+	}
+	if !d.queryLogging || d.logger == nil {
+		return q
+	}
+	return &loggedQueryer{Queryer: q, logger: d.logger, slowQueryThreshold: d.slowQueryThreshold}
+}
+
+// WithTx runs fn in a transaction, it is committed when fn returns nil and rolled back when fn returns an error or
+// panics. When ctx already has a transaction, fn runs in a savepoint of it, so only the changes of fn are rolled back.
+func (d *DatabaseExtension) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if t, ok := ctx.Value(dbTxKey{}).(*dbTx); ok {
+		return d.withSavepoint(ctx, t, fn)
+	}
+
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, dbTxKey{}, &dbTx{tx: tx}))
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			d.logger.Error("Failed to roll back transaction", slog.Any("err", rbErr))
+		}
+		return err
+	}
+	// A transaction is assumed to write, so the rest of the request reads from the primary
+	markWritten(ctx)
+	return tx.Commit()
+}
+
+// withSavepoint runs fn in a savepoint of the transaction
+func (d *DatabaseExtension) withSavepoint(ctx context.Context, t *dbTx, fn func(ctx context.Context) error) (err error) {
+	nested := &dbTx{tx: t.tx, depth: t.depth + 1}
+	savepoint := fmt.Sprintf("bat_savepoint_%d", nested.depth)
+	_, err = t.tx.ExecContext(ctx, "SAVEPOINT "+savepoint)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_, _ = t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint)
+			panic(r)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, dbTxKey{}, nested))
+	if err != nil {
+		if _, rbErr := t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rbErr != nil {
+			d.logger.Error("Failed to roll back to savepoint", slog.String("savepoint", savepoint), slog.Any("err", rbErr))
+		}
+		return err
+	}
+	_, err = t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint)
+	return err
+}
+
+// TransactionMiddleware runs the request in a transaction, it is rolled back when the handler returns an error or
+// responds with a status of 400 or higher. The transaction is finished right before the response is written, so a
+// failed commit turns the response into a 500 instead of confirming changes that were not saved. When the request
+// already runs in a transaction, the handler runs in it.
+func (d *DatabaseExtension) TransactionMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if _, ok := req.Context().Value(dbTxKey{}).(*dbTx); ok {
+				return next(c)
+			}
+
+			tx, err := d.db.BeginTxx(req.Context(), nil)
+			if err != nil {
+				return err
+			}
+
+			finished := false
+			var commitErr error
+			// finish commits or rolls back the transaction once, it returns whether it did so
+			finish := func(rollback bool) bool {
+				if finished {
+					return false
+				}
+				finished = true
+				if rollback {
+					if rbErr := tx.Rollback(); rbErr != nil {
+						d.logger.Error("Failed to roll back transaction", slog.Any("err", rbErr))
+					}
+					return true
+				}
+				markWritten(req.Context())
+				commitErr = tx.Commit()
+				return true
+			}
+
+			res := c.Response()
+			res.Before(func() {
+				if finish(res.Status >= http.StatusBadRequest) && commitErr != nil {
+					d.logger.Error("Failed to commit transaction", slog.Any("err", commitErr))
+					res.Status = http.StatusInternalServerError
+					res.Header().Del(echo.HeaderLocation)
+					res.Header().Del(echo.HeaderContentLength)
+					res.Writer = discardBodyWriter{ResponseWriter: res.Writer}
+				}
+			})
+			defer func() {
+				if r := recover(); r != nil {
+					finish(true)
+					panic(r)
+				}
+			}()
+
+			c.SetRequest(req.WithContext(context.WithValue(req.Context(), dbTxKey{}, &dbTx{tx: tx})))
+			err = next(c)
+			c.SetRequest(req)
+
+			// Handlers that did not write a response finish the transaction here
+			finish(err != nil || res.Status >= http.StatusBadRequest)
+			if err != nil {
+				return err
+			}
+			return commitErr
+		}
+	}
+}
+
+// discardBodyWriter writes the status and headers of a response but drops its body
+type discardBodyWriter struct {
+	http.ResponseWriter
+}
+
+func (w discardBodyWriter) Write(b []byte) (int, error) {
+	return len(b), nil
 }
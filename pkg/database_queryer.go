@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"log/slog"
+	"time"
+)
+
+// Queryer is implemented by *sqlx.DB and *sqlx.Tx, so queries can run inside and outside a transaction
+type Queryer interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+	NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error)
+}
+
+// loggedQueryer logs every query with its duration
+type loggedQueryer struct {
+	Queryer
+	logger             *Logger
+	slowQueryThreshold time.Duration
+}
+
+// log logs the query, slow queries are logged on warn level
+func (l *loggedQueryer) log(ctx context.Context, query string, start time.Time, err error) {
+	duration := time.Since(start)
+	attrs := []slog.Attr{slog.String("query", query), slog.Duration("duration", duration)}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		attrs = append(attrs, slog.Any("err", err))
+	}
+
+	if l.slowQueryThreshold > 0 && duration >= l.slowQueryThreshold {
+		l.logger.LogAttrs(ctx, slog.LevelWarn, "Slow query", attrs...)
+		return
+	}
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "Query", attrs...)
+}
+
+func (l *loggedQueryer) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := l.Queryer.QueryContext(ctx, query, args...)
+	l.log(ctx, query, start, err)
+	return rows, err
+}
+
+func (l *loggedQueryer) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	start := time.Now()
+	rows, err := l.Queryer.QueryxContext(ctx, query, args...)
+	l.log(ctx, query, start, err)
+	return rows, err
+}
+
+func (l *loggedQueryer) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	start := time.Now()
+	row := l.Queryer.QueryRowxContext(ctx, query, args...)
+	l.log(ctx, query, start, row.Err())
+	return row
+}
+
+func (l *loggedQueryer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := l.Queryer.ExecContext(ctx, query, args...)
+	l.log(ctx, query, start, err)
+	return res, err
+}
+
+func (l *loggedQueryer) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	start := time.Now()
+	err := l.Queryer.GetContext(ctx, dest, query, args...)
+	l.log(ctx, query, start, err)
+	return err
+}
+
+func (l *loggedQueryer) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	start := time.Now()
+	err := l.Queryer.SelectContext(ctx, dest, query, args...)
+	l.log(ctx, query, start, err)
+	return err
+}
+
+func (l *loggedQueryer) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	start := time.Now()
+	res, err := l.Queryer.NamedExecContext(ctx, query, arg)
+	l.log(ctx, query, start, err)
+	return res, err
+}
@@ -0,0 +1,202 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dbPrimaryKey is the context key that sends all queries of a context to the primary database
+type dbPrimaryKey struct{}
+
+// dbStickyKey is the context key of the sticky state of a request
+type dbStickyKey struct{}
+
+// dbSticky remembers whether a request wrote to the primary database
+type dbSticky struct {
+	written atomic.Bool
+}
+
+// dbReplica is a read replica with its health
+type dbReplica struct {
+	db      *sqlx.DB
+	index   int
+	healthy atomic.Bool
+}
+
+// UsePrimary returns a context in which all queries are sent to the primary database, for reads that can not see
+// stale data or selects that write
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dbPrimaryKey{}, true)
+}
+
+// markWritten makes the request of ctx sticky to the primary database
+func markWritten(ctx context.Context) {
+	if sticky, ok := ctx.Value(dbStickyKey{}).(*dbSticky); ok {
+		sticky.written.Store(true)
+	}
+}
+
+// isReadQuery returns whether the query only reads, so it can run on a replica
+func isReadQuery(query string) bool {
+	q := strings.TrimLeft(query, " \t\r\n(")
+	if len(q) < 6 || !strings.EqualFold(q[:6], "SELECT") {
+		return false
+	}
+	upper := strings.ToUpper(q)
+	return !strings.Contains(upper, " FOR UPDATE") && !strings.Contains(upper, " FOR SHARE")
+}
+
+// isConnectionError returns whether the error means the database can not be reached
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr)
+}
+
+// replica returns the next healthy replica, or nil when all replicas are unhealthy
+func (d *DatabaseExtension) replica() *dbReplica {
+	start := d.nextReplica.Add(1)
+	for i := range uint64(len(d.replicas)) {
+		r := d.replicas[(start+i)%uint64(len(d.replicas))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// markUnhealthy takes the replica out of rotation until the health check sees it again
+func (d *DatabaseExtension) markUnhealthy(r *dbReplica, err error) {
+	if r.healthy.CompareAndSwap(true, false) {
+		d.logger.Warn("Database replica is unhealthy, failing over to the primary", slog.Int("replica", r.index), slog.Any("err", err))
+	}
+}
+
+// checkReplicas pings the replicas until ctx is done
+func (d *DatabaseExtension) checkReplicas(ctx context.Context) {
+	ticker := time.NewTicker(d.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, r := range d.replicas {
+			pingCtx, cancel := context.WithTimeout(ctx, d.healthCheckInterval)
+			err := r.db.PingContext(pingCtx)
+			cancel()
+			if err != nil {
+				d.markUnhealthy(r, err)
+				continue
+			}
+			if r.healthy.CompareAndSwap(false, true) {
+				d.logger.Info("Database replica is healthy again", slog.Int("replica", r.index))
+			}
+		}
+	}
+}
+
+// routingQueryer sends read queries to a replica and all other queries to the primary database, it falls back to the
+// primary when the replica can not be reached
+type routingQueryer struct {
+	// Queryer is the primary database
+	Queryer
+	ext *DatabaseExtension
+	ctx context.Context
+}
+
+// route returns the database for the query, replica is nil when it is the primary database
+func (r *routingQueryer) route(query string) (q Queryer, replica *dbReplica) {
+	if !isReadQuery(query) {
+		markWritten(r.ctx)
+		return r.Queryer, nil
+	}
+	if primary, _ := r.ctx.Value(dbPrimaryKey{}).(bool); primary {
+		return r.Queryer, nil
+	}
+	if sticky, ok := r.ctx.Value(dbStickyKey{}).(*dbSticky); ok && sticky.written.Load() {
+		return r.Queryer, nil
+	}
+	replica = r.ext.replica()
+	if replica == nil {
+		return r.Queryer, nil
+	}
+	return replica.db, replica
+}
+
+// failover returns whether the query has to run again on the primary database
+func (r *routingQueryer) failover(replica *dbReplica, err error) bool {
+	if replica == nil || !isConnectionError(err) {
+		return false
+	}
+	r.ext.markUnhealthy(replica, err)
+	return true
+}
+
+func (r *routingQueryer) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	q, replica := r.route(query)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if r.failover(replica, err) {
+		return r.Queryer.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (r *routingQueryer) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	q, replica := r.route(query)
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if r.failover(replica, err) {
+		return r.Queryer.QueryxContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (r *routingQueryer) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	q, replica := r.route(query)
+	row := q.QueryRowxContext(ctx, query, args...)
+	if r.failover(replica, row.Err()) {
+		return r.Queryer.QueryRowxContext(ctx, query, args...)
+	}
+	return row
+}
+
+func (r *routingQueryer) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	q, replica := r.route(query)
+	err := q.GetContext(ctx, dest, query, args...)
+	if r.failover(replica, err) {
+		return r.Queryer.GetContext(ctx, dest, query, args...)
+	}
+	return err
+}
+
+func (r *routingQueryer) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	q, replica := r.route(query)
+	err := q.SelectContext(ctx, dest, query, args...)
+	if r.failover(replica, err) {
+		return r.Queryer.SelectContext(ctx, dest, query, args...)
+	}
+	return err
+}
+
+func (r *routingQueryer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	markWritten(r.ctx)
+	return r.Queryer.ExecContext(ctx, query, args...)
+}
+
+func (r *routingQueryer) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	markWritten(r.ctx)
+	return r.Queryer.NamedExecContext(ctx, query, arg)
+}
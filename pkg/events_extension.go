@@ -0,0 +1,352 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultEventsPath = "/events"
+const DefaultEventsKeyPrefix = "events:"
+const DefaultEventsHeartbeatInterval = 15 * time.Second
+const DefaultEventsBufferSize = 64
+
+// sessionChannelPrefix is the prefix of the private channel of every session
+const sessionChannelPrefix = "session:"
+
+// eventsResubscribeDelay is the time to wait before subscribing again after a subscription failed
+const eventsResubscribeDelay = time.Second
+
+var InvalidEventNameError = errors.New("event name must not contain line breaks")
+
+// ChannelAuthorizer returns whether the request may subscribe to the channel
+type ChannelAuthorizer func(c echo.Context, channel string) (bool, error)
+
+// eventMessage is a published event, it is encoded as JSON to send it through the PubSub
+type eventMessage struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// eventsClient is a connected event stream
+type eventsClient struct {
+	messages chan eventMessage
+}
+
+// eventsChannel is a channel with at least one connected client, it has one subscription per instance of the app
+type eventsChannel struct {
+	cancel  context.CancelFunc
+	clients map[*eventsClient]struct{}
+}
+
+// EventsExtension is an extension that streams events to the browser with server-sent events. Every session has its own
+// channel, other channels can be subscribed to when the ChannelAuthorizer allows it. The events are sent through
+// valkey pub/sub by default, so events published on any instance reach the clients on all instances.
+type EventsExtension struct {
+	pubSub            PubSub
+	logger            *Logger
+	sessionExtension  *SessionExtension
+	path              string
+	keyPrefix         string
+	heartbeatInterval time.Duration
+	bufferSize        int
+	authorizer        ChannelAuthorizer
+	mu                sync.Mutex
+	channels          map[string]*eventsChannel
+	// ctx is cancelled when the server shuts down, so the open streams are closed
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// EventsExtensionOption is a function that modifies the EventsExtension
+type EventsExtensionOption func(*EventsExtension) error
+
+// WithEventsPubSub sets the pub/sub used to deliver the events, by default the client of the ValkeyExtension is used
+func WithEventsPubSub(pubSub PubSub) EventsExtensionOption {
+	return func(e *EventsExtension) error {
+		e.pubSub = pubSub
+		return nil
+	}
+}
+
+// WithEventsPath sets the path of the event stream endpoint
+func WithEventsPath(path string) EventsExtensionOption {
+	return func(e *EventsExtension) error {
+		e.path = path
+		return nil
+	}
+}
+
+// WithEventsKeyPrefix sets the prefix of all pub/sub channels
+func WithEventsKeyPrefix(prefix string) EventsExtensionOption {
+	return func(e *EventsExtension) error {
+		e.keyPrefix = prefix
+		return nil
+	}
+}
+
+// WithEventsHeartbeatInterval sets the interval of the heartbeat comments, they keep proxies from closing idle streams
+func WithEventsHeartbeatInterval(interval time.Duration) EventsExtensionOption {
+	return func(e *EventsExtension) error {
+		e.heartbeatInterval = interval
+		return nil
+	}
+}
+
+// WithEventsBufferSize sets how many events are buffered per client, events for a client with a full buffer are dropped
+func WithEventsBufferSize(size int) EventsExtensionOption {
+	return func(e *EventsExtension) error {
+		e.bufferSize = size
+		return nil
+	}
+}
+
+// WithChannelAuthorizer sets the authorizer of the channels a client asks for with the channel query parameter, without
+// an authorizer clients can only receive the events of their own session
+func WithChannelAuthorizer(authorizer ChannelAuthorizer) EventsExtensionOption {
+	return func(e *EventsExtension) error {
+		e.authorizer = authorizer
+		return nil
+	}
+}
+
+// NewEventsExtension creates a new events extension
+func NewEventsExtension(opts ...EventsExtensionOption) (*EventsExtension, error) {
+	ext := &EventsExtension{
+		path:              DefaultEventsPath,
+		keyPrefix:         DefaultEventsKeyPrefix,
+		heartbeatInterval: DefaultEventsHeartbeatInterval,
+		bufferSize:        DefaultEventsBufferSize,
+		channels:          make(map[string]*eventsChannel),
+	}
+
+	for _, opt := range opts {
+		err := opt(ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ext, nil
+}
+
+// Register registers the events extension and the event stream endpoint
+func (e *EventsExtension) Register(app *Bat) error {
+	e.logger = &Logger{app.Logger.With("module", "events_extension")}
+	e.sessionExtension = GetExtension[*SessionExtension](app)
+	if e.pubSub == nil {
+		e.pubSub = NewValkeyPubSub(GetExtension[*ValkeyExtension](app).GetClient())
+	}
+
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	// The streams never become idle, so they have to be closed as soon as the server starts shutting down
+	app.Echo.Server.RegisterOnShutdown(e.cancel)
+	app.OnShutdown(func(context.Context) error {
+		e.cancel()
+		return nil
+	})
+
+	app.GET(e.path, e.stream)
+	return nil
+}
+
+// Requirements returns the requirements for the events extension
+func (e *EventsExtension) Requirements() []reflect.Type {
+	if e.pubSub == nil {
+		return []reflect.Type{
+			reflect.TypeOf(ValkeyExtension{}),
+			reflect.TypeOf(SessionExtension{}),
+		}
+	}
+	return []reflect.Type{
+		reflect.TypeOf(SessionExtension{}),
+	}
+}
+
+// Publish sends the event with the data encoded as JSON to all clients that subscribed to the channel
+func (e *EventsExtension) Publish(ctx context.Context, channel, event string, data any) error {
+	if strings.ContainsAny(event, "\r\n") {
+		return InvalidEventNameError
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	message, err := json.Marshal(eventMessage{Event: event, Data: b})
+	if err != nil {
+		return err
+	}
+	return e.pubSub.Publish(ctx, e.keyPrefix+channel, message)
+}
+
+// PublishToSession sends the event to all clients of the session
+func (e *EventsExtension) PublishToSession(ctx context.Context, sessionID, event string, data any) error {
+	return e.Publish(ctx, sessionChannelPrefix+sessionID, event, data)
+}
+
+// stream is the handler of the event stream endpoint, the client receives the events of its session and of the
+// authorized channels in the channel query parameters
+func (e *EventsExtension) stream(c echo.Context) error {
+	sessionID, _ := c.Request().Context().Value(e.sessionExtension.sessionKey).(string)
+	if sessionID == "" {
+		return echo.ErrUnauthorized
+	}
+
+	channels, err := e.authorizedChannels(c, sessionID)
+	if err != nil {
+		return err
+	}
+
+	client := &eventsClient{messages: make(chan eventMessage, e.bufferSize)}
+	for _, channel := range channels {
+		e.subscribe(channel, client)
+	}
+	defer func() {
+		for _, channel := range channels {
+			e.unsubscribe(channel, client)
+		}
+	}()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	// Disable response buffering in nginx
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	// Let the browser reconnect after 3 seconds when the connection drops
+	_, err = fmt.Fprint(res, "retry: 3000\n\n")
+	if err != nil {
+		return nil
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(e.heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-e.ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(res, ": heartbeat\n\n")
+		case message := <-client.messages:
+			_, err = fmt.Fprintf(res, "event: %s\ndata: %s\n\n", message.Event, message.Data)
+		}
+		if err != nil {
+			// The client disconnected
+			return nil
+		}
+		res.Flush()
+	}
+}
+
+// authorizedChannels returns the channels of the request, it returns an error if one of the channels is not allowed
+func (e *EventsExtension) authorizedChannels(c echo.Context, sessionID string) ([]string, error) {
+	own := sessionChannelPrefix + sessionID
+	channels := []string{own}
+	for _, param := range c.QueryParams()["channel"] {
+		for _, channel := range strings.Split(param, ",") {
+			channel = strings.TrimSpace(channel)
+			if channel == "" || channel == own {
+				continue
+			}
+			// The channels of other sessions are never allowed
+			if strings.HasPrefix(channel, sessionChannelPrefix) || e.authorizer == nil {
+				return nil, echo.NewHTTPError(http.StatusForbidden, "not allowed to subscribe to "+channel)
+			}
+			allowed, err := e.authorizer(c, channel)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				return nil, echo.NewHTTPError(http.StatusForbidden, "not allowed to subscribe to "+channel)
+			}
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+// subscribe adds the client to the channel, the first client of a channel starts the subscription of this instance
+func (e *EventsExtension) subscribe(channel string, client *eventsClient) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ch, ok := e.channels[channel]
+	if !ok {
+		ctx, cancel := context.WithCancel(e.ctx)
+		ch = &eventsChannel{cancel: cancel, clients: make(map[*eventsClient]struct{})}
+		e.channels[channel] = ch
+		go e.listen(ctx, channel)
+	}
+	ch.clients[client] = struct{}{}
+}
+
+// unsubscribe removes the client from the channel, the last client of a channel stops the subscription
+func (e *EventsExtension) unsubscribe(channel string, client *eventsClient) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ch, ok := e.channels[channel]
+	if !ok {
+		return
+	}
+	delete(ch.clients, client)
+	if len(ch.clients) == 0 {
+		ch.cancel()
+		delete(e.channels, channel)
+	}
+}
+
+// listen subscribes to the channel until ctx is done, the subscription is retried when it fails
+func (e *EventsExtension) listen(ctx context.Context, channel string) {
+	for {
+		err := e.pubSub.Subscribe(ctx, e.keyPrefix+channel, func(message []byte) {
+			e.dispatch(channel, message)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		e.logger.Error("Subscription failed, resubscribing", slog.String("channel", channel), slog.Any("err", err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventsResubscribeDelay):
+		}
+	}
+}
+
+// dispatch sends the message to all clients of the channel, clients that can not keep up miss the message
+func (e *EventsExtension) dispatch(channel string, message []byte) {
+	var msg eventMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		e.logger.Error("Failed to decode event", slog.String("channel", channel), slog.Any("err", err))
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ch, ok := e.channels[channel]
+	if !ok {
+		return
+	}
+	for client := range ch.clients {
+		select {
+		case client.messages <- msg:
+		default:
+			e.logger.Warn("Dropping event for slow client", slog.String("channel", channel), slog.String("event", msg.Event))
+		}
+	}
+}
@@ -147,3 +147,9 @@ func GetExtension[T Extension](b *Bat) T {
 	ext := b.extensions[reflect.TypeOf((*T)(nil)).Elem()]
 	return ext.(T)
 }
+
+// LookupExtension returns the extension of type T, the boolean is false if the extension is not registered
+func LookupExtension[T Extension](b *Bat) (T, bool) {
+	ext, ok := b.extensions[reflect.TypeOf((*T)(nil)).Elem()].(T)
+	return ext, ok
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/gob"
+	"github.com/labstack/echo/v4"
 	"github.com/romsar/gonertia/v2"
 	"github.com/valkey-io/valkey-go"
 	"log/slog"
@@ -60,11 +61,17 @@ func NewFlashExtension(opts ...FlashExtensionOption) (*FlashExtension, error) {
 	return ext, nil
 }
 
-// Register registers the flash extension
+// Register registers the flash extension, it is the ErrorFlasher of the requests
 func (f *FlashExtension) Register(app *Bat) error {
 	f.logger = app.Logger.With("module", "flash_extension")
 	f.client = GetExtension[*SessionExtension](app).vClient
 	f.sessionExtension = GetExtension[*SessionExtension](app)
+	app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(ErrorFlasherKey, f)
+			return next(c)
+		}
+	})
 	return nil
 }
 
@@ -1,33 +1,47 @@
 package pkg
 
 import (
+	"context"
+	"crypto/md5"
 	"crypto/sha256"
 	_ "embed"
-	"encoding/json"
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/romsar/gonertia/v2"
 	"html/template"
+	"io"
 	"io/fs"
 	"log/slog"
+	"net/http"
 	"net/url"
-	"os/exec"
 	"path"
 	"reflect"
+	"regexp"
 	"strings"
+	"time"
 )
 
-// DefaultIgnoreList is the default list of paths that will be ignored by the dev proxy
-var DefaultIgnoreList = []string{"api", "swagger"}
+// DefaultIgnoreList is the default list of path prefixes that will be ignored by the dev proxy
+var DefaultIgnoreList = []string{"/api", "/swagger"}
+
+// DefaultProxyPrefixes is the default list of path prefixes that will be proxied to the dev server
+var DefaultProxyPrefixes = []string{"/@vite", "/@fs", "/@id", "/@react-refresh", "/src", "/node_modules"}
+
+// viteBuildDir is the path the built vite assets are served on in prod
+const viteBuildDir = "build"
 
 //go:embed inertia_root.gohtml
 var DefaultInertiaRootTemplate []byte
 
+// VersionMismatchHandler is called when an Inertia request was made with an outdated asset version, after it returns
+// without error the client is forced to do a full page visit
+type VersionMismatchHandler func(c echo.Context, clientVersion, serverVersion string) error
+
 // InertiaExtensionOption is a function that sets an option on the InertiaExtension
 type InertiaExtensionOption func(i *InertiaExtension) error
 
-// WithIgnoreList sets the ignore list on the InertiaExtension
+// WithIgnoreList sets the ignore list on the InertiaExtension, paths starting with one of the prefixes are not proxied
 func WithIgnoreList(ignoreList []string) InertiaExtensionOption {
 	return func(i *InertiaExtension) error {
 		i.ignoreList = ignoreList
@@ -35,6 +49,14 @@ func WithIgnoreList(ignoreList []string) InertiaExtensionOption {
 	}
 }
 
+// WithProxyPrefixes sets the path prefixes that are proxied to the dev server on the InertiaExtension
+func WithProxyPrefixes(prefixes []string) InertiaExtensionOption {
+	return func(i *InertiaExtension) error {
+		i.proxyPrefixes = prefixes
+		return nil
+	}
+}
+
 // WithFrontendPath sets the frontend path on the InertiaExtension
 func WithFrontendPath(frontendPath string) InertiaExtensionOption {
 	return func(i *InertiaExtension) error {
@@ -59,6 +81,58 @@ func WithDevServerURL(devServerURL string) InertiaExtensionOption {
 	}
 }
 
+// WithDevServerReadyTimeout sets how long the InertiaExtension waits for the dev server to become ready
+func WithDevServerReadyTimeout(timeout time.Duration) InertiaExtensionOption {
+	return func(i *InertiaExtension) error {
+		i.devServerReadyTimeout = timeout
+		return nil
+	}
+}
+
+// WithDevServerRestartDelay sets how long the InertiaExtension waits before restarting a crashed dev server, the delay
+// doubles every time the dev server crashes again
+func WithDevServerRestartDelay(delay time.Duration) InertiaExtensionOption {
+	return func(i *InertiaExtension) error {
+		i.devServerRestartDelay = delay
+		return nil
+	}
+}
+
+// WithManagedDevServer sets whether the InertiaExtension starts and supervises the dev server, disable it when the dev
+// server is started separately
+func WithManagedDevServer(managed bool) InertiaExtensionOption {
+	return func(i *InertiaExtension) error {
+		i.manageDevServer = managed
+		return nil
+	}
+}
+
+// WithVersion sets the asset version on the InertiaExtension, by default it is computed from the build
+func WithVersion(version string) InertiaExtensionOption {
+	return func(i *InertiaExtension) error {
+		i.version = version
+		return nil
+	}
+}
+
+// WithVersionMismatchHandler sets the handler that is called when the client has an outdated asset version
+func WithVersionMismatchHandler(handler VersionMismatchHandler) InertiaExtensionOption {
+	return func(i *InertiaExtension) error {
+		i.versionMismatchHandler = handler
+		return nil
+	}
+}
+
+// WithHashedAssetPattern sets the pattern that matches hashed asset file names on the InertiaExtension, these are served
+// with an immutable Cache-Control header like the files of the vite manifest. Set it to nil to only serve the files of
+// the manifest as immutable.
+func WithHashedAssetPattern(pattern *regexp.Regexp) InertiaExtensionOption {
+	return func(i *InertiaExtension) error {
+		i.hashedAssetPattern = pattern
+		return nil
+	}
+}
+
 // WithRootTemplate sets the root template on the InertiaExtension
 func WithRootTemplate(rootTemplate []byte) InertiaExtensionOption {
 	return func(i *InertiaExtension) error {
@@ -70,14 +144,20 @@ func WithRootTemplate(rootTemplate []byte) InertiaExtensionOption {
 // NewInertiaExtension creates a new InertiaExtension
 func NewInertiaExtension(distDirFS fs.FS, manifest []byte, isDev bool, opts ...InertiaExtensionOption) (*InertiaExtension, error) {
 	ext := &InertiaExtension{
-		rootTemplate: DefaultInertiaRootTemplate,
-		manifest:     manifest,
-		isDev:        isDev,
-		ignoreList:   DefaultIgnoreList,
-		frontendPath: "./frontend",
-		jsRuntime:    "bun",
-		devServerURL: "http://localhost:5173/",
-		distDirFS:    distDirFS,
+		rootTemplate:  DefaultInertiaRootTemplate,
+		manifest:      manifest,
+		isDev:         isDev,
+		ignoreList:    DefaultIgnoreList,
+		proxyPrefixes: DefaultProxyPrefixes,
+		frontendPath:  "./frontend",
+		jsRuntime:     "bun",
+		devServerURL:  "http://localhost:5173/",
+		distDirFS:     distDirFS,
+
+		hashedAssetPattern:    DefaultHashedAssetPattern,
+		manageDevServer:       true,
+		devServerReadyTimeout: 30 * time.Second,
+		devServerRestartDelay: time.Second,
 	}
 
 	for _, opt := range opts {
@@ -100,27 +180,102 @@ type InertiaExtension struct {
 	rootTemplate []byte
 	// manifest is the manifest file that is used to get the vite assets, this is set in the NewInertiaExtension function
 	manifest []byte
+	// viteManifest is the parsed manifest, this is set in the Register function
+	viteManifest ViteManifest
 	// logger is a logger that will be used by the InertiaExtension to log messages, this is set in the Register function
 	logger *Logger
 	// flashExtension is the flash extension that will be used by the InertiaExtension to create a flash provider, this is set in the Register function
 	flashExtension *FlashExtension
 	// isDev is a boolean that is set to true if the environment is dev, this is set in the Register function
 	isDev bool
-	// ignoreList is a list of paths that will be ignored by the dev proxy.
+	// ignoreList is a list of path prefixes that will be ignored by the dev proxy.
 	ignoreList []string
+	// proxyPrefixes is a list of path prefixes that will be proxied to the dev server.
+	proxyPrefixes []string
 	// frontendPath is the path to the frontend directory in the dir from the root of the project, this is used to start the dev server
 	frontendPath string
 	// jsRuntime is the path to the JS runtime that will be used by the InertiaExtension to run JS code.
 	jsRuntime string
 	// devServerURL is the URL of the dev server that will be used by the InertiaExtension to proxy requests to the dev server
 	devServerURL string
+	// manageDevServer is a boolean that is set to true if the InertiaExtension starts and supervises the dev server
+	manageDevServer bool
+	// devServerReadyTimeout is the time the dev server gets to become ready before requests to it fail
+	devServerReadyTimeout time.Duration
+	// devServerRestartDelay is the time to wait before the first restart of a crashed dev server
+	devServerRestartDelay time.Duration
+	// devServer is the supervised dev server process, this is set in the setupDevProxy function
+	devServer *viteDevServer
+	// hashedAssetPattern matches the asset file names that contain a content hash
+	hashedAssetPattern *regexp.Regexp
+	// version is the asset version, if it is empty it is computed from the build in the Register function
+	version string
+	// versionMismatchHandler is called when the client has an outdated asset version
+	versionMismatchHandler VersionMismatchHandler
 }
 
-// createHash creates a hash from the root template
-func (i *InertiaExtension) createHash() string {
+// createHash creates a hash from the root template, the manifest and the files in the dist directory, so every new
+// frontend build results in a new version. In dev the assets are served by the dev server, so only the root template
+// is hashed.
+func (i *InertiaExtension) createHash() (string, error) {
 	hash := sha256.New()
 	hash.Write(i.rootTemplate)
-	return fmt.Sprintf("%x", hash.Sum(nil))
+	if i.isDev {
+		return fmt.Sprintf("%x", hash.Sum(nil)), nil
+	}
+
+	hash.Write(i.manifest)
+	if i.distDirFS != nil {
+		err := fs.WalkDir(i.distDirFS, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			f, err := i.distDirFS.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			hash.Write([]byte(p))
+			_, err = io.Copy(hash, f)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// Version returns the asset version
+func (i *InertiaExtension) Version() string {
+	return i.version
+}
+
+// versionMismatch is a middleware that calls the version mismatch handler when the client has an outdated asset version
+func (i *InertiaExtension) versionMismatch() echo.MiddlewareFunc {
+	// gonertia hashes the version before sending it to the client
+	serverVersion := fmt.Sprintf("%x", md5.Sum([]byte(i.version)))
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			if i.versionMismatchHandler == nil || !gonertia.IsInertiaRequest(r) || r.Method != http.MethodGet {
+				return next(c)
+			}
+
+			clientVersion := r.Header.Get("X-Inertia-Version")
+			if clientVersion != serverVersion {
+				i.logger.Debug("Inertia asset version mismatch", slog.String("client", clientVersion), slog.String("server", serverVersion))
+				err := i.versionMismatchHandler(c, clientVersion, serverVersion)
+				if err != nil {
+					return err
+				}
+			}
+			return next(c)
+		}
+	}
 }
 
 // Register registers the InertiaExtension
@@ -128,16 +283,37 @@ func (i *InertiaExtension) Register(app *Bat) error {
 	i.flashExtension = GetExtension[*FlashExtension](app)
 	i.logger = &Logger{app.Logger.With(slog.String("module", "inertia"))}
 	var err error
+	i.viteManifest, err = ParseViteManifest(i.manifest)
+	if err != nil {
+		if !i.isDev {
+			i.logger.Error("Failed to parse vite manifest", slog.Any("err", err))
+			return err
+		}
+		// In dev the assets are served by the dev server, so the manifest is not needed
+		i.logger.Warn("Failed to parse vite manifest, ignoring because of dev mode", slog.Any("err", err))
+	}
+	if i.version == "" {
+		i.version, err = i.createHash()
+		if err != nil {
+			i.logger.Error("Failed to compute the asset version", slog.Any("err", err))
+			return err
+		}
+	}
+	i.logger.Debug("Using asset version", slog.String("version", i.version))
 	i.Inertia, err = gonertia.NewFromBytes(
 		i.rootTemplate,
-		gonertia.WithVersion(i.createHash()),
+		gonertia.WithVersion(i.version),
 		gonertia.WithLogger(i.logger),
 		gonertia.WithFlashProvider(i.flashExtension))
 	if err != nil {
 		i.logger.Error("Failed to initialize Inertia", slog.Any("err", err))
 		return err
 	}
-	err = i.Inertia.ShareTemplateFunc("vite", i.vite("build"))
+	err = i.Inertia.ShareTemplateFunc("vite", i.vite(viteBuildDir))
+	if err != nil {
+		return err
+	}
+	err = i.Inertia.ShareTemplateFunc("viteTags", i.viteTags(viteBuildDir))
 	if err != nil {
 		return err
 	}
@@ -149,18 +325,20 @@ func (i *InertiaExtension) Register(app *Bat) error {
 	if err != nil {
 		return err
 	}
-	app.Echo.Use(echo.WrapMiddleware(i.Inertia.Middleware))
+	app.Echo.Use(i.versionMismatch(), echo.WrapMiddleware(i.Inertia.Middleware))
 	if i.isDev {
 		i.logger.Debug("Setting up dev proxy")
 		err := i.setupDevProxy(app)
 		if err != nil {
-			i.logger.Error("Failed to setup dev proxy", err)
+			i.logger.Error("Failed to setup dev proxy", slog.Any("err", err))
 			return err
 		}
 		return nil
 	}
 
-	app.Echo.StaticFS("/build", i.distDirFS)
+	assets := newStaticAssets(i.distDirFS, i.viteManifest, i.hashedAssetPattern)
+	app.Echo.GET("/"+viteBuildDir+"/*", assets.Handler())
+	app.Echo.HEAD("/"+viteBuildDir+"/*", assets.Handler())
 	return nil
 }
 
@@ -174,30 +352,65 @@ func (i *InertiaExtension) Requirements() []reflect.Type {
 
 // vite is a helper function that returns a function that returns the vite asset path
 func (i *InertiaExtension) vite(buildDir string) func(path string) (string, error) {
-	viteAssets := make(map[string]*struct {
-		File   string `json:"file"`
-		Source string `json:"src"`
-	})
-	err := json.Unmarshal(i.manifest, &viteAssets)
-	if err != nil {
-		i.logger.Error("Failed to unmarshal vite manifest file to json", err)
+	return func(p string) (string, error) {
+		// If in dev mode, the asset is served by the dev server
+		if i.isDev {
+			return path.Join("/", p), nil
+		}
+		// If in prod mode, return the dist asset path
+		chunk, err := i.viteManifest.Chunk(p)
+		if err != nil {
+			return "", err
+		}
+		return path.Join("/", buildDir, chunk.File), nil
 	}
+}
 
-	return func(p string) (string, error) {
-		// If in dev mode and the asset is in the viteAssets map, return the vite asset path
+// viteTags is a helper function that returns a function that returns the script, stylesheet and modulepreload tags for an entry
+func (i *InertiaExtension) viteTags(buildDir string) func(entry string) (template.HTML, error) {
+	return func(entry string) (template.HTML, error) {
+		// If in dev mode, the dev server injects the stylesheets and imports
 		if i.isDev {
-			if _, ok := viteAssets[p]; ok {
-				return path.Join("/", p), nil
-			}
+			return template.HTML(scriptTag(path.Join("/", entry))), nil
+		}
+
+		chunk, err := i.viteManifest.Chunk(entry)
+		if err != nil {
+			return "", err
+		}
+		css, err := i.viteManifest.CSS(entry)
+		if err != nil {
+			return "", err
+		}
+		imports, err := i.viteManifest.ImportedChunks(entry)
+		if err != nil {
+			return "", err
+		}
+
+		var tags strings.Builder
+		for _, file := range css {
+			tags.WriteString(stylesheetTag(path.Join("/", buildDir, file)))
 		}
-		// If in prod mode and the asset is in the viteAssets map, return the dist asset path
-		if val, ok := viteAssets[p]; ok {
-			return path.Join("/", buildDir, val.File), nil
+		tags.WriteString(scriptTag(path.Join("/", buildDir, chunk.File)))
+		for _, imp := range imports {
+			tags.WriteString(modulePreloadTag(path.Join("/", buildDir, imp.File)))
 		}
-		return "", fmt.Errorf("asset %q not found", p)
+		return template.HTML(tags.String()), nil
 	}
 }
 
+func scriptTag(src string) string {
+	return "<script type=\"module\" src=\"" + template.HTMLEscapeString(src) + "\"></script>"
+}
+
+func stylesheetTag(href string) string {
+	return "<link rel=\"stylesheet\" href=\"" + template.HTMLEscapeString(href) + "\">"
+}
+
+func modulePreloadTag(href string) string {
+	return "<link rel=\"modulepreload\" href=\"" + template.HTMLEscapeString(href) + "\">"
+}
+
 // reactRefresh is a helper function that returns a function that returns the react refresh script
 func (i *InertiaExtension) reactRefresh() func() template.HTML {
 	return func() template.HTML {
@@ -226,18 +439,21 @@ func (i *InertiaExtension) viteHead() func() template.HTML {
 
 // setupDevProxy sets up a proxy to the vite dev server
 func (i *InertiaExtension) setupDevProxy(bat *Bat) error {
-	cmd := exec.Command(i.jsRuntime, "run", "dev")
-	cmd.Dir = i.frontendPath
-	err := cmd.Start()
-	if err != nil {
-		i.logger.Error("Failed to start the dev server", err)
-	}
-
 	url, err := url.Parse(i.devServerURL)
 	if err != nil {
-		i.logger.Error("Failed to parse the URL for the dev server", err, url)
+		i.logger.Error("Failed to parse the URL for the dev server", slog.Any("err", err), slog.String("url", i.devServerURL))
 		return err
 	}
+
+	if i.manageDevServer {
+		i.devServer = newViteDevServer(i.logger, i.jsRuntime, i.frontendPath, i.devServerURL, i.devServerReadyTimeout, i.devServerRestartDelay)
+		err = i.devServer.Start()
+		if err != nil {
+			return err
+		}
+		bat.OnShutdown(i.devServer.Stop)
+	}
+
 	// Setup a proxy to the vite dev server on localhost:5173
 	balancer := middleware.NewRoundRobinBalancer([]*middleware.ProxyTarget{
 		{
@@ -248,20 +464,74 @@ func (i *InertiaExtension) setupDevProxy(bat *Bat) error {
 	px := middleware.ProxyWithConfig(middleware.ProxyConfig{
 		Balancer: balancer,
 		Skipper: func(c echo.Context) bool {
-			// Skip the proxy if the path is in the ignore list
-			for _, ignorePath := range i.ignoreList {
-				if strings.Contains(c.Path(), ignorePath) {
-					return true
-				}
-			}
-
-			return false
+			return !i.shouldProxy(c.Request())
 		},
 	})
 
-	bat.Group("/src").Use(px)
-	bat.Group("/@*").Use(px)
-	bat.Group("/node_modules").Use(px)
+	// The proxy is used as a global middleware so it also receives requests that do not match a route
+	bat.Use(i.waitForDevServer(), px)
 
 	return nil
 }
+
+// shouldProxy returns true if the request has to be proxied to the dev server
+func (i *InertiaExtension) shouldProxy(r *http.Request) bool {
+	for _, ignorePrefix := range i.ignoreList {
+		if hasPathPrefix(r.URL.Path, ignorePrefix) {
+			return false
+		}
+	}
+
+	if isViteHMRRequest(r) {
+		return true
+	}
+
+	for _, prefix := range i.proxyPrefixes {
+		if hasPathPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPathPrefix returns true if the path equals the prefix or is below it, so "/api" matches "/api/users" but not "/apiary"
+func hasPathPrefix(p, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// isViteHMRRequest returns true if the request is the vite HMR websocket or its ping, these are made to the base path
+func isViteHMRRequest(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get(echo.HeaderUpgrade), "websocket") {
+		for _, protocol := range strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",") {
+			protocol = strings.TrimSpace(protocol)
+			if protocol == "vite-hmr" || protocol == "vite-ping" {
+				return true
+			}
+		}
+	}
+	return r.Header.Get(echo.HeaderAccept) == "text/x-vite-ping"
+}
+
+// waitForDevServer is a middleware that holds requests until the managed dev server is ready
+func (i *InertiaExtension) waitForDevServer() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if i.devServer == nil || !i.shouldProxy(c.Request()) {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), i.devServerReadyTimeout)
+			defer cancel()
+			if err := i.devServer.WaitReady(ctx); err != nil {
+				i.logger.Warn("Dev server is not ready", slog.Any("err", err))
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "dev server is not ready")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"github.com/gorilla/websocket"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDevProxyForwardsViteHMRWebsocket(t *testing.T) {
+	// The fake dev server echoes every frame it gets on the HMR websocket
+	upgrader := websocket.Upgrader{Subprotocols: []string{"vite-hmr"}}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				return
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	logger := &Logger{slog.New(slog.NewTextHandler(io.Discard, nil))}
+	app, err := NewBat(logger)
+	if err != nil {
+		t.Fatalf("NewBat returned %v", err)
+	}
+	ext, err := NewInertiaExtension(nil, nil, true, WithDevServerURL(upstream.URL), WithManagedDevServer(false))
+	if err != nil {
+		t.Fatalf("NewInertiaExtension returned %v", err)
+	}
+	ext.logger = logger
+	if err := ext.setupDevProxy(app); err != nil {
+		t.Fatalf("setupDevProxy returned %v", err)
+	}
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	// The HMR websocket is opened on the base path which is not one of the proxy prefixes
+	dialer := websocket.Dialer{Subprotocols: []string{"vite-hmr"}, HandshakeTimeout: 5 * time.Second}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/", nil)
+	if err != nil {
+		t.Fatalf("Dial through the proxy returned %v", err)
+	}
+	defer conn.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Dial returned status %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	if conn.Subprotocol() != "vite-hmr" {
+		t.Fatalf("Subprotocol is %q, want %q", conn.Subprotocol(), "vite-hmr")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping"}`)); err != nil {
+		t.Fatalf("WriteMessage returned %v", err)
+	}
+	messageType, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage returned %v", err)
+	}
+	if messageType != websocket.TextMessage || string(message) != `{"type":"ping"}` {
+		t.Fatalf("ReadMessage returned %d %q, want the echoed frame", messageType, message)
+	}
+}
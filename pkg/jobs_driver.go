@@ -0,0 +1,311 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/valkey-io/valkey-go"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Job is a unit of work that is run by a worker
+type Job struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Queue       string          `json:"queue"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempt     int             `json:"attempt"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	EnqueuedAt  time.Time       `json:"enqueued_at"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+// JobHandleFunc handles a job that is taken from a queue, it takes care of retrying and dead lettering failed jobs
+type JobHandleFunc func(ctx context.Context, job *Job)
+
+// JobsDriver is the queue used by the JobsExtension
+type JobsDriver interface {
+	// Enqueue stores the job, so it is run by a worker at job.RunAt
+	Enqueue(ctx context.Context, job *Job) error
+	// Work runs the jobs of the queues with handle until ctx is done, handle is called at most concurrency times at
+	// the same time. It waits for the running jobs before it returns.
+	Work(ctx context.Context, queues []string, concurrency int, handle JobHandleFunc) error
+	// DeadLetter stores a job that failed too often, so it can be inspected
+	DeadLetter(ctx context.Context, job *Job) error
+}
+
+const (
+	// jobsConsumerGroup is the consumer group shared by all workers
+	jobsConsumerGroup = "workers"
+	// jobsPollInterval is the time a worker blocks while waiting for jobs, and the interval in which delayed jobs are
+	// moved to their queue
+	jobsPollInterval = time.Second
+	// jobsPromoteBatchSize is the maximum amount of delayed jobs moved to their queue at once
+	jobsPromoteBatchSize = 100
+	// jobsDeadLetterMaxLen is the approximate maximum amount of jobs kept in the dead letter stream
+	jobsDeadLetterMaxLen = "10000"
+)
+
+// promoteScript moves the delayed jobs that are due from the sorted set to the stream of the queue
+var promoteScript = valkey.NewLuaScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local jobs = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", now, "LIMIT", 0, tonumber(ARGV[1]))
+for _, job in ipairs(jobs) do
+	redis.call("XADD", KEYS[2], "*", "job", job)
+	redis.call("ZREM", KEYS[1], job)
+end
+return #jobs
+`)
+
+// ValkeyJobsDriver is a JobsDriver that stores the jobs in valkey streams, one stream per queue. Delayed jobs are kept
+// in a sorted set per queue until they are due. Jobs of a worker that crashed are taken over by another worker after
+// the visibility timeout.
+type ValkeyJobsDriver struct {
+	client            valkey.Client
+	keyPrefix         string
+	visibilityTimeout time.Duration
+	consumer          string
+}
+
+// NewValkeyJobsDriver creates a new valkey jobs driver, the key prefix should contain a hash tag (like "{jobs}:") so all
+// keys are in the same slot when valkey runs as a cluster
+func NewValkeyJobsDriver(client valkey.Client, keyPrefix string, visibilityTimeout time.Duration) *ValkeyJobsDriver {
+	hostname, _ := os.Hostname()
+	id, _ := newJobID()
+	return &ValkeyJobsDriver{
+		client:            client,
+		keyPrefix:         keyPrefix,
+		visibilityTimeout: visibilityTimeout,
+		consumer:          hostname + "-" + strconv.Itoa(os.Getpid()) + "-" + id[:8],
+	}
+}
+
+func (v *ValkeyJobsDriver) streamKey(queue string) string {
+	return v.keyPrefix + "queue:" + queue
+}
+
+func (v *ValkeyJobsDriver) delayedKey(queue string) string {
+	return v.keyPrefix + "delayed:" + queue
+}
+
+func (v *ValkeyJobsDriver) deadLetterKey() string {
+	return v.keyPrefix + "dead"
+}
+
+// Enqueue adds the job to the stream of its queue, or to the delayed jobs when it should run later
+func (v *ValkeyJobsDriver) Enqueue(ctx context.Context, job *Job) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	if job.RunAt.After(time.Now()) {
+		return v.client.Do(ctx, v.client.B().Zadd().Key(v.delayedKey(job.Queue)).ScoreMember().
+			ScoreMember(float64(job.RunAt.UnixMilli()), string(b)).Build()).Error()
+	}
+	return v.client.Do(ctx, v.client.B().Xadd().Key(v.streamKey(job.Queue)).Id("*").
+		FieldValue().FieldValue("job", string(b)).Build()).Error()
+}
+
+// DeadLetter adds the job to the dead letter stream
+func (v *ValkeyJobsDriver) DeadLetter(ctx context.Context, job *Job) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return v.deadLetterRaw(ctx, string(b))
+}
+
+func (v *ValkeyJobsDriver) deadLetterRaw(ctx context.Context, job string) error {
+	return v.client.Do(ctx, v.client.B().Xadd().Key(v.deadLetterKey()).Maxlen().Almost().Threshold(jobsDeadLetterMaxLen).
+		Id("*").FieldValue().FieldValue("job", job).Build()).Error()
+}
+
+// Work reads the jobs of the queues from the streams and runs them with handle
+func (v *ValkeyJobsDriver) Work(ctx context.Context, queues []string, concurrency int, handle JobHandleFunc) error {
+	streams := make([]string, len(queues))
+	ids := make([]string, len(queues))
+	for i, queue := range queues {
+		streams[i] = v.streamKey(queue)
+		ids[i] = ">"
+		err := v.client.Do(ctx, v.client.B().XgroupCreate().Key(streams[i]).Group(jobsConsumerGroup).Id("0").Mkstream().Build()).Error()
+		if verr, ok := valkey.IsValkeyErr(err); err != nil && !(ok && verr.IsBusyGroup()) {
+			return err
+		}
+	}
+
+	// Running jobs are not stopped when ctx is done, they are waited for instead
+	jobCtx := context.WithoutCancel(ctx)
+	run := func(job jobEntry) {
+		// Claimed entries that were deleted in the meantime have no fields, they only need to be acknowledged
+		if job.entry.FieldValues != nil {
+			var decoded Job
+			if err := json.Unmarshal([]byte(job.entry.FieldValues["job"]), &decoded); err != nil {
+				// A job that can not be decoded will never succeed, so it is moved to the dead letters as is
+				_ = v.deadLetterRaw(jobCtx, job.entry.FieldValues["job"])
+			} else {
+				handle(jobCtx, &decoded)
+			}
+		}
+		v.client.DoMulti(jobCtx,
+			v.client.B().Xack().Key(job.stream).Group(jobsConsumerGroup).Id(job.entry.ID).Build(),
+			v.client.B().Xdel().Key(job.stream).Id(job.entry.ID).Build())
+	}
+
+	var lastPromote, lastClaim time.Time
+	read := func(count int) (map[string][]valkey.XRangeEntry, error) {
+		if time.Since(lastPromote) >= jobsPollInterval {
+			lastPromote = time.Now()
+			if err := v.promote(ctx, queues); err != nil {
+				return nil, err
+			}
+		}
+		claim := time.Since(lastClaim) >= v.visibilityTimeout/2
+		if claim {
+			lastClaim = time.Now()
+		}
+		return v.read(ctx, streams, ids, count, claim)
+	}
+	return workJobEntries(ctx, streams, concurrency, read, run)
+}
+
+// jobEntry is an entry of the stream of a queue
+type jobEntry struct {
+	stream string
+	entry  valkey.XRangeEntry
+}
+
+// workJobEntries reads entries with read and runs them with run until ctx is done, at most concurrency at the same
+// time. read is asked for as many entries as there are free slots, but XREADGROUP applies its count to every stream,
+// so the entries that do not fit are kept and run first when slots are free again. Kept entries that are not run
+// before ctx is done stay pending and are taken over by another worker after the visibility timeout.
+func workJobEntries(ctx context.Context, streams []string, concurrency int, read func(count int) (map[string][]valkey.XRangeEntry, error), run func(job jobEntry)) error {
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var queued []jobEntry
+	for ctx.Err() == nil {
+		// Wait for a free slot, and take all other free slots so they can be filled in one read
+		select {
+		case <-ctx.Done():
+			return nil
+		case slots <- struct{}{}:
+		}
+		free := 1
+	fill:
+		for free < concurrency {
+			select {
+			case slots <- struct{}{}:
+				free++
+			default:
+				break fill
+			}
+		}
+
+		var err error
+		if len(queued) == 0 {
+			var entries map[string][]valkey.XRangeEntry
+			entries, err = read(free)
+			for _, stream := range streams {
+				for _, entry := range entries[stream] {
+					queued = append(queued, jobEntry{stream: stream, entry: entry})
+				}
+			}
+		}
+
+		// Every started job holds one of the taken slots
+		used := min(free, len(queued))
+		for _, job := range queued[:used] {
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				run(job)
+			}()
+		}
+		queued = queued[used:]
+		// Give back the slots that were not filled
+		for ; used < free; used++ {
+			<-slots
+		}
+
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read returns at most count entries of the streams, when claim is true the entries of workers that did not acknowledge
+// them within the visibility timeout are taken over first
+func (v *ValkeyJobsDriver) read(ctx context.Context, streams, ids []string, count int, claim bool) (map[string][]valkey.XRangeEntry, error) {
+	entries := make(map[string][]valkey.XRangeEntry)
+	if claim {
+		claimed := 0
+		minIdle := strconv.FormatInt(v.visibilityTimeout.Milliseconds(), 10)
+		for _, stream := range streams {
+			if claimed >= count {
+				break
+			}
+			res, err := v.client.Do(ctx, v.client.B().Xautoclaim().Key(stream).Group(jobsConsumerGroup).Consumer(v.consumer).
+				MinIdleTime(minIdle).Start("0-0").Count(int64(count-claimed)).Build()).ToArray()
+			if err != nil {
+				return entries, err
+			}
+			if len(res) < 2 {
+				continue
+			}
+			streamEntries, err := res[1].AsXRange()
+			if err != nil {
+				return entries, err
+			}
+			entries[stream] = streamEntries
+			claimed += len(streamEntries)
+		}
+		if claimed > 0 {
+			return entries, nil
+		}
+	}
+
+	res, err := v.client.Do(ctx, v.client.B().Xreadgroup().Group(jobsConsumerGroup, v.consumer).Count(int64(count)).
+		Block(jobsPollInterval.Milliseconds()).Streams().Key(streams...).Id(ids...).Build()).AsXRead()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			// No jobs arrived while blocking
+			return entries, nil
+		}
+		return entries, err
+	}
+	return res, nil
+}
+
+// promote moves the delayed jobs that are due to their queue
+func (v *ValkeyJobsDriver) promote(ctx context.Context, queues []string) error {
+	for _, queue := range queues {
+		err := promoteScript.Exec(ctx, v.client, []string{v.delayedKey(queue), v.streamKey(queue)},
+			[]string{strconv.Itoa(jobsPromoteBatchSize)}).Error()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newJobID returns a random ID for a job
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"context"
+	"github.com/valkey-io/valkey-go"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkJobEntriesKeepsConcurrencyWithTwoQueues(t *testing.T) {
+	streams := []string{"queue:default", "queue:mail"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var reads []int
+	read := func(count int) (map[string][]valkey.XRangeEntry, error) {
+		reads = append(reads, count)
+		if len(reads) > 1 {
+			time.Sleep(time.Millisecond)
+			return nil, nil
+		}
+		// XREADGROUP returns count entries of every stream
+		return map[string][]valkey.XRangeEntry{
+			"queue:default": {{ID: "1-0"}},
+			"queue:mail":    {{ID: "2-0"}},
+		}, nil
+	}
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	var ran []string
+	run := func(job jobEntry) {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		ran = append(ran, job.stream+"/"+job.entry.ID)
+		if len(ran) == 2 {
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- workJobEntries(ctx, streams, 1, read, run)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("workJobEntries returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("workJobEntries did not return")
+	}
+
+	if maxRunning != 1 {
+		t.Errorf("ran %d jobs at the same time, want 1", maxRunning)
+	}
+	if len(ran) != 2 || ran[0] != "queue:default/1-0" || ran[1] != "queue:mail/2-0" {
+		t.Errorf("ran %v, want both entries in the order of the streams", ran)
+	}
+	for _, count := range reads {
+		if count != 1 {
+			t.Errorf("read %d entries, want 1", count)
+		}
+	}
+}
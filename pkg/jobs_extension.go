@@ -0,0 +1,317 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const DefaultJobsKeyPrefix = "{jobs}:"
+const DefaultJobQueue = "default"
+const DefaultJobMaxAttempts = 3
+const DefaultJobsConcurrency = 10
+const DefaultJobsVisibilityTimeout = 5 * time.Minute
+
+// jobsRestartDelay is the time to wait before the driver is restarted after it failed
+const jobsRestartDelay = time.Second
+
+var UnknownJobError = errors.New("no handler registered for job")
+
+// JobHandler runs a job, a returned error makes the job retry until it runs out of attempts
+type JobHandler func(ctx context.Context, job *Job) error
+
+// JobBackoff returns the time to wait before the next attempt of a job that failed attempt times
+type JobBackoff func(attempt int) time.Duration
+
+// DefaultJobBackoff waits exponentially longer after every attempt, starting at 1 second and capped at 1 hour, with
+// up to 20% jitter so failed jobs do not retry at the same time
+func DefaultJobBackoff(attempt int) time.Duration {
+	backoff := time.Hour
+	if attempt < 13 {
+		backoff = min(time.Second<<(attempt-1), time.Hour)
+	}
+	return backoff + time.Duration(rand.Int64N(int64(backoff)/5+1))
+}
+
+// JobOption is a function that modifies a job when it is enqueued
+type JobOption func(*Job)
+
+// JobQueue sets the queue of the job, by default DefaultJobQueue is used
+func JobQueue(queue string) JobOption {
+	return func(j *Job) {
+		j.Queue = queue
+	}
+}
+
+// JobDelay delays the job, so it runs after the delay
+func JobDelay(delay time.Duration) JobOption {
+	return func(j *Job) {
+		j.RunAt = time.Now().Add(delay)
+	}
+}
+
+// JobRunAt schedules the job, so it runs at the given time
+func JobRunAt(runAt time.Time) JobOption {
+	return func(j *Job) {
+		j.RunAt = runAt
+	}
+}
+
+// JobMaxAttempts sets how often the job is attempted before it is dead lettered
+func JobMaxAttempts(attempts int) JobOption {
+	return func(j *Job) {
+		j.MaxAttempts = attempts
+	}
+}
+
+// JobsExtension is an extension that runs jobs in the background, on top of valkey streams or a custom JobsDriver
+type JobsExtension struct {
+	driver            JobsDriver
+	logger            *Logger
+	keyPrefix         string
+	visibilityTimeout time.Duration
+	concurrency       int
+	maxAttempts       int
+	backoff           JobBackoff
+	handlersMu        sync.RWMutex
+	handlers          map[string]JobHandler
+}
+
+// JobsExtensionOption is a function that modifies the JobsExtension
+type JobsExtensionOption func(*JobsExtension) error
+
+// WithJobsDriver sets the driver of the jobs, by default the client of the ValkeyExtension is used
+func WithJobsDriver(driver JobsDriver) JobsExtensionOption {
+	return func(j *JobsExtension) error {
+		j.driver = driver
+		return nil
+	}
+}
+
+// WithJobsKeyPrefix sets the prefix of all job keys, it only applies to the default valkey driver
+func WithJobsKeyPrefix(prefix string) JobsExtensionOption {
+	return func(j *JobsExtension) error {
+		j.keyPrefix = prefix
+		return nil
+	}
+}
+
+// WithJobsVisibilityTimeout sets after how long the jobs of a crashed worker are taken over by another worker, it only
+// applies to the default valkey driver. It should be longer than the longest running job.
+func WithJobsVisibilityTimeout(timeout time.Duration) JobsExtensionOption {
+	return func(j *JobsExtension) error {
+		j.visibilityTimeout = timeout
+		return nil
+	}
+}
+
+// WithJobsConcurrency sets how many jobs a worker runs at the same time
+func WithJobsConcurrency(concurrency int) JobsExtensionOption {
+	return func(j *JobsExtension) error {
+		if concurrency < 1 {
+			return fmt.Errorf("jobs concurrency must be at least 1, got %d", concurrency)
+		}
+		j.concurrency = concurrency
+		return nil
+	}
+}
+
+// WithJobsMaxAttempts sets how often a job is attempted before it is dead lettered, it can be overridden per job
+func WithJobsMaxAttempts(attempts int) JobsExtensionOption {
+	return func(j *JobsExtension) error {
+		j.maxAttempts = attempts
+		return nil
+	}
+}
+
+// WithJobsBackoff sets the time to wait between attempts of a failed job
+func WithJobsBackoff(backoff JobBackoff) JobsExtensionOption {
+	return func(j *JobsExtension) error {
+		j.backoff = backoff
+		return nil
+	}
+}
+
+// NewJobsExtension creates a new jobs extension
+func NewJobsExtension(opts ...JobsExtensionOption) (*JobsExtension, error) {
+	ext := &JobsExtension{
+		keyPrefix:         DefaultJobsKeyPrefix,
+		visibilityTimeout: DefaultJobsVisibilityTimeout,
+		concurrency:       DefaultJobsConcurrency,
+		maxAttempts:       DefaultJobMaxAttempts,
+		backoff:           DefaultJobBackoff,
+		handlers:          make(map[string]JobHandler),
+	}
+
+	for _, opt := range opts {
+		err := opt(ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ext, nil
+}
+
+// Register registers the jobs extension
+func (j *JobsExtension) Register(app *Bat) error {
+	j.logger = &Logger{app.Logger.With("module", "jobs_extension")}
+	if j.driver == nil {
+		j.driver = NewValkeyJobsDriver(GetExtension[*ValkeyExtension](app).GetClient(), j.keyPrefix, j.visibilityTimeout)
+	}
+	if syncDriver, ok := j.driver.(*SyncJobsDriver); ok {
+		syncDriver.handle = j.handle
+	}
+	return nil
+}
+
+// Requirements returns the requirements for the jobs extension
+func (j *JobsExtension) Requirements() []reflect.Type {
+	if j.driver == nil {
+		return []reflect.Type{
+			reflect.TypeOf(ValkeyExtension{}),
+		}
+	}
+	return []reflect.Type{}
+}
+
+// Driver returns the driver of the jobs
+func (j *JobsExtension) Driver() JobsDriver {
+	return j.driver
+}
+
+// Handle registers the handler of the jobs with the name, only workers need the handlers
+func (j *JobsExtension) Handle(name string, handler JobHandler) {
+	j.handlersMu.Lock()
+	defer j.handlersMu.Unlock()
+	j.handlers[name] = handler
+}
+
+// Enqueue adds a job with the payload to a queue, the payload is encoded as JSON
+func (j *JobsExtension) Enqueue(ctx context.Context, name string, payload any, opts ...JobOption) (*Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          id,
+		Name:        name,
+		Queue:       DefaultJobQueue,
+		Payload:     b,
+		MaxAttempts: j.maxAttempts,
+		RunAt:       now,
+		EnqueuedAt:  now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	err = j.driver.Enqueue(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	j.logger.Debug("Enqueued job", slog.String("job", job.Name), slog.String("id", job.ID), slog.String("queue", job.Queue))
+	return job, nil
+}
+
+// Work runs the jobs of the queues until ctx is done, by default the DefaultJobQueue is used. It waits for the running
+// jobs to finish before it returns.
+func (j *JobsExtension) Work(ctx context.Context, queues ...string) error {
+	if len(queues) == 0 {
+		queues = []string{DefaultJobQueue}
+	}
+
+	j.logger.Info("Starting worker", slog.Any("queues", queues), slog.Int("concurrency", j.concurrency))
+	for {
+		err := j.driver.Work(ctx, queues, j.concurrency, j.handle)
+		if ctx.Err() != nil {
+			j.logger.Info("Worker stopped")
+			return nil
+		}
+		if err != nil {
+			j.logger.Error("Worker failed, restarting", slog.Any("err", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			j.logger.Info("Worker stopped")
+			return nil
+		case <-time.After(jobsRestartDelay):
+		}
+	}
+}
+
+// handle runs the job, a failed job is enqueued again after the backoff or dead lettered when it ran out of attempts
+func (j *JobsExtension) handle(ctx context.Context, job *Job) {
+	logger := j.logger.With(slog.String("job", job.Name), slog.String("id", job.ID), slog.String("queue", job.Queue))
+
+	j.handlersMu.RLock()
+	handler, ok := j.handlers[job.Name]
+	j.handlersMu.RUnlock()
+	if !ok {
+		logger.Error("No handler registered for job, dead lettering it")
+		job.LastError = UnknownJobError.Error()
+		if err := j.driver.DeadLetter(ctx, job); err != nil {
+			logger.Error("Failed to dead letter job", slog.Any("err", err))
+		}
+		return
+	}
+
+	job.Attempt++
+	start := time.Now()
+	err := runJob(ctx, handler, job)
+	if err == nil {
+		logger.Debug("Job succeeded", slog.Int("attempt", job.Attempt), slog.Duration("duration", time.Since(start)))
+		return
+	}
+
+	job.LastError = err.Error()
+	if job.Attempt >= job.MaxAttempts {
+		logger.Error("Job failed, dead lettering it", slog.Int("attempt", job.Attempt), slog.Any("err", err))
+		if err := j.driver.DeadLetter(ctx, job); err != nil {
+			logger.Error("Failed to dead letter job", slog.Any("err", err))
+		}
+		return
+	}
+
+	backoff := j.backoff(job.Attempt)
+	job.RunAt = time.Now().Add(backoff)
+	logger.Warn("Job failed, retrying", slog.Int("attempt", job.Attempt), slog.Duration("backoff", backoff), slog.Any("err", err))
+	if err := j.driver.Enqueue(ctx, job); err != nil {
+		logger.Error("Failed to enqueue job for retry", slog.Any("err", err))
+	}
+}
+
+// runJob runs the handler, a panic is returned as an error so it is retried like any other failure
+func runJob(ctx context.Context, handler JobHandler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// RegisterJob registers a handler that receives the payload of the job decoded into T
+func RegisterJob[T any](j *JobsExtension, name string, handler func(ctx context.Context, payload T) error) {
+	j.Handle(name, func(ctx context.Context, job *Job) error {
+		var payload T
+		err := json.Unmarshal(job.Payload, &payload)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, payload)
+	})
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+)
+
+// SyncJobsDriver is a JobsDriver that runs the jobs in process as soon as they are enqueued, it is meant for tests.
+// Delays and backoff are ignored, so a failing job is retried right away until it is dead lettered.
+type SyncJobsDriver struct {
+	mu          sync.Mutex
+	handle      JobHandleFunc
+	processed   []Job
+	deadLetters []Job
+}
+
+// NewSyncJobsDriver creates a new synchronous jobs driver
+func NewSyncJobsDriver() *SyncJobsDriver {
+	return &SyncJobsDriver{}
+}
+
+// Enqueue runs the job right away
+func (s *SyncJobsDriver) Enqueue(ctx context.Context, job *Job) error {
+	s.mu.Lock()
+	s.processed = append(s.processed, *job)
+	s.mu.Unlock()
+
+	s.handle(ctx, job)
+	return nil
+}
+
+// Work blocks until ctx is done, the jobs are already run when they are enqueued
+func (s *SyncJobsDriver) Work(ctx context.Context, _ []string, _ int, _ JobHandleFunc) error {
+	<-ctx.Done()
+	return nil
+}
+
+// DeadLetter stores the job, so it can be inspected with DeadLetters
+func (s *SyncJobsDriver) DeadLetter(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLetters = append(s.deadLetters, *job)
+	return nil
+}
+
+// Processed returns every run of a job, a job that is retried is returned once per attempt
+func (s *SyncJobsDriver) Processed() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Job(nil), s.processed...)
+}
+
+// DeadLetters returns the jobs that failed too often
+func (s *SyncJobsDriver) DeadLetters() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Job(nil), s.deadLetters...)
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/valkey-io/valkey-go"
+	"sync"
+	"time"
+)
+
+// Locker provides locks that expire, so a crashed holder does not keep the lock forever
+type Locker interface {
+	// Lock tries to acquire a lock on the key that expires after ttl, it returns false if the lock is held by someone else
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(ctx context.Context) error, acquired bool, err error)
+}
+
+// unlockScript deletes the lock only if it is still held by the same owner
+var unlockScript = valkey.NewLuaScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`)
+
+// ValkeyLocker is a Locker that stores the locks in valkey, so they are shared between all instances of the app
+type ValkeyLocker struct {
+	client valkey.Client
+}
+
+// NewValkeyLocker creates a new valkey locker
+func NewValkeyLocker(client valkey.Client) *ValkeyLocker {
+	return &ValkeyLocker{client: client}
+}
+
+// Lock tries to acquire a lock on the key
+func (v *ValkeyLocker) Lock(ctx context.Context, key string, ttl time.Duration) (func(ctx context.Context) error, bool, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = v.client.Do(ctx, v.client.B().Set().Key(key).Value(token).Nx().Px(ttl).Build()).Error()
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			// The key is already set, so the lock is held by someone else
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	unlock := func(ctx context.Context) error {
+		return unlockScript.Exec(ctx, v.client, []string{key}, []string{token}).Error()
+	}
+	return unlock, true, nil
+}
+
+// MemoryLocker is a Locker that keeps the locks in memory, it is meant for tests and single instance apps
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryCacheEntry
+}
+
+// NewMemoryLocker creates a new in-memory locker
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{
+		locks: make(map[string]memoryCacheEntry),
+	}
+}
+
+// Lock tries to acquire a lock on the key
+func (m *MemoryLocker) Lock(_ context.Context, key string, ttl time.Duration) (func(ctx context.Context) error, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if lock, ok := m.locks[key]; ok && !lock.expired(now) {
+		return nil, false, nil
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return nil, false, err
+	}
+	m.locks[key] = memoryCacheEntry{value: []byte(token), expiresAt: now.Add(ttl)}
+
+	unlock := func(context.Context) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if lock, ok := m.locks[key]; ok && string(lock.value) == token {
+			delete(m.locks, key)
+		}
+		return nil
+	}
+	return unlock, true, nil
+}
+
+// newLockToken returns a random token that identifies the owner of a lock
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
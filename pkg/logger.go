@@ -33,9 +33,9 @@ type Logger struct {
 }
 
 func (l *Logger) Printf(format string, v ...interface{}) {
-	l.Info(fmt.Sprintf(format, v))
+	l.Info(fmt.Sprintf(format, v...))
 }
 
 func (l *Logger) Println(v ...interface{}) {
-	l.Info(fmt.Sprint(v))
+	l.Info(fmt.Sprint(v...))
 }
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"context"
+	"github.com/valkey-io/valkey-go"
+	"sync"
+)
+
+// PubSub delivers messages published on a channel to all subscribers of that channel
+type PubSub interface {
+	// Publish sends the message to all subscribers of the channel
+	Publish(ctx context.Context, channel string, message []byte) error
+	// Subscribe calls handler for every message published on the channel until ctx is done
+	Subscribe(ctx context.Context, channel string, handler func(message []byte)) error
+}
+
+// ValkeyPubSub is a PubSub on top of valkey pub/sub, so messages published on any instance of the app reach the
+// subscribers on all instances
+type ValkeyPubSub struct {
+	client valkey.Client
+}
+
+// NewValkeyPubSub creates a new valkey pub/sub
+func NewValkeyPubSub(client valkey.Client) *ValkeyPubSub {
+	return &ValkeyPubSub{client: client}
+}
+
+// Publish publishes the message on the channel
+func (v *ValkeyPubSub) Publish(ctx context.Context, channel string, message []byte) error {
+	return v.client.Do(ctx, v.client.B().Publish().Channel(channel).Message(valkey.BinaryString(message)).Build()).Error()
+}
+
+// Subscribe subscribes to the channel until ctx is done
+func (v *ValkeyPubSub) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	err := v.client.Receive(ctx, v.client.B().Subscribe().Channel(channel).Build(), func(msg valkey.PubSubMessage) {
+		handler([]byte(msg.Message))
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// MemoryPubSub is a PubSub that delivers the messages in process, it is meant for tests and single instance apps
+type MemoryPubSub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*memorySubscriber]struct{}
+}
+
+type memorySubscriber struct {
+	handler func(message []byte)
+}
+
+// NewMemoryPubSub creates a new in-memory pub/sub
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{
+		subscribers: make(map[string]map[*memorySubscriber]struct{}),
+	}
+}
+
+// Publish calls the handlers of all subscribers of the channel
+func (m *MemoryPubSub) Publish(_ context.Context, channel string, message []byte) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for sub := range m.subscribers[channel] {
+		sub.handler(append([]byte(nil), message...))
+	}
+	return nil
+}
+
+// Subscribe subscribes to the channel until ctx is done
+func (m *MemoryPubSub) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	sub := &memorySubscriber{handler: handler}
+
+	m.mu.Lock()
+	if _, ok := m.subscribers[channel]; !ok {
+		m.subscribers[channel] = make(map[*memorySubscriber]struct{})
+	}
+	m.subscribers[channel][sub] = struct{}{}
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	delete(m.subscribers[channel], sub)
+	if len(m.subscribers[channel]) == 0 {
+		delete(m.subscribers, channel)
+	}
+	m.mu.Unlock()
+	return nil
+}
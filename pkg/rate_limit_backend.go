@@ -0,0 +1,180 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"github.com/valkey-io/valkey-go"
+	"math"
+	"strconv"
+	"time"
+)
+
+var InvalidRateLimitError = errors.New("rate limit must allow at least one request in a positive period")
+
+// RateLimitAlgorithm is the algorithm used to limit the requests
+type RateLimitAlgorithm int
+
+const (
+	// RateLimitSlidingWindow allows Requests per Period, the previous period is weighted in so bursts at the edge of a
+	// period are not allowed
+	RateLimitSlidingWindow RateLimitAlgorithm = iota
+	// RateLimitTokenBucket allows bursts of Requests, after which one request is allowed every Period/Requests
+	RateLimitTokenBucket
+)
+
+// RateLimit describes how many requests are allowed in a period
+type RateLimit struct {
+	Requests  int
+	Period    time.Duration
+	Algorithm RateLimitAlgorithm
+}
+
+func (l RateLimit) validate() error {
+	if l.Requests <= 0 || l.Period <= 0 {
+		return InvalidRateLimitError
+	}
+	return nil
+}
+
+// RateLimitResult is the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully reset
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, it is 0 if the request is allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitBackend is the storage used by the RateLimitExtension
+type RateLimitBackend interface {
+	// Allow counts a request for the key and returns whether it is allowed by the limit
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// slidingWindowScript counts the request in the current window if the weighted count of the previous and current window
+// is below the limit, it returns whether the request is allowed, both counts and the elapsed time of the current window
+var slidingWindowScript = valkey.NewLuaScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local index = math.floor(now / window)
+local elapsed = now % window
+local current = tonumber(redis.call("HGET", KEYS[1], tostring(index)) or "0")
+local previous = tonumber(redis.call("HGET", KEYS[1], tostring(index - 1)) or "0")
+local allowed = 0
+if previous * (window - elapsed) / window + current < limit then
+	allowed = 1
+	current = redis.call("HINCRBY", KEYS[1], tostring(index), 1)
+	redis.call("HDEL", KEYS[1], tostring(index - 2))
+	redis.call("PEXPIRE", KEYS[1], window * 2)
+end
+return {allowed, previous, current, elapsed}
+`)
+
+// tokenBucketScript refills the bucket for the elapsed time and takes a token if there is one, it returns whether the
+// request is allowed and the remaining tokens in thousandths
+var tokenBucketScript = valkey.NewLuaScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / interval)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity * interval))
+return {allowed, math.floor(tokens * 1000)}
+`)
+
+// ValkeyRateLimitBackend is a RateLimitBackend that stores the counters in valkey, so the limits are shared between all
+// instances of the app. The time of the valkey server is used, so the clocks of the instances do not matter.
+type ValkeyRateLimitBackend struct {
+	client valkey.Client
+}
+
+// NewValkeyRateLimitBackend creates a new valkey rate limit backend
+func NewValkeyRateLimitBackend(client valkey.Client) *ValkeyRateLimitBackend {
+	return &ValkeyRateLimitBackend{client: client}
+}
+
+// Allow counts a request for the key and returns whether it is allowed by the limit
+func (v *ValkeyRateLimitBackend) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	if err := limit.validate(); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	switch limit.Algorithm {
+	case RateLimitTokenBucket:
+		interval := float64(limit.Period) / float64(time.Millisecond) / float64(limit.Requests)
+		res, err := tokenBucketScript.Exec(ctx, v.client, []string{key}, []string{
+			strconv.Itoa(limit.Requests),
+			strconv.FormatFloat(interval, 'f', -1, 64),
+		}).AsIntSlice()
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		return tokenBucketResult(limit, res[0] == 1, float64(res[1])/1000), nil
+	default:
+		res, err := slidingWindowScript.Exec(ctx, v.client, []string{key}, []string{
+			strconv.Itoa(limit.Requests),
+			strconv.FormatInt(limit.Period.Milliseconds(), 10),
+		}).AsIntSlice()
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		return slidingWindowResult(limit, res[0] == 1, res[1], res[2], time.Duration(res[3])*time.Millisecond), nil
+	}
+}
+
+// slidingWindowResult creates the result from the counts of the previous and current window
+func slidingWindowResult(limit RateLimit, allowed bool, previous, current int64, elapsed time.Duration) RateLimitResult {
+	window := float64(limit.Period)
+	left := limit.Period - elapsed
+	weighted := float64(previous)*float64(left)/window + float64(current)
+
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: max(0, limit.Requests-int(math.Ceil(weighted))),
+		Reset:     left,
+	}
+	if allowed {
+		return res
+	}
+
+	requests := float64(limit.Requests)
+	if float64(current) < requests {
+		// The request is allowed as soon as the weight of the previous window dropped enough
+		res.RetryAfter = left - time.Duration((requests-float64(current))*window/float64(previous))
+	} else {
+		// The current window is full, so it has to become the previous window and lose enough weight
+		res.RetryAfter = left + time.Duration(window*(1-requests/float64(current)))
+	}
+	res.RetryAfter = max(res.RetryAfter, time.Millisecond)
+	return res
+}
+
+// tokenBucketResult creates the result from the tokens left in the bucket
+func tokenBucketResult(limit RateLimit, allowed bool, tokens float64) RateLimitResult {
+	interval := float64(limit.Period) / float64(limit.Requests)
+
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(limit.Requests) - tokens) * interval),
+	}
+	if !allowed {
+		res.RetryAfter = max(time.Duration((1-tokens)*interval), time.Millisecond)
+	}
+	return res
+}
@@ -0,0 +1,214 @@
+package pkg
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/romsar/gonertia/v2"
+	"log/slog"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const DefaultRateLimitKeyPrefix = "rate_limit:"
+
+// RateLimitErrorKey is the key of the validation error that is flashed when an Inertia request is rate limited
+const RateLimitErrorKey = "rate_limit"
+
+// RateLimitKeyFunc returns the key that identifies who is rate limited, like an IP address or a user ID
+type RateLimitKeyFunc func(c echo.Context) (string, error)
+
+// RateLimitExceededHandler handles a request that exceeded the rate limit
+type RateLimitExceededHandler func(c echo.Context, rule RateLimitRule, result RateLimitResult) error
+
+// RateLimitRule configures a rate limit middleware
+type RateLimitRule struct {
+	// Name separates the counters of different rules, so the same key can be limited by multiple rules
+	Name string
+	RateLimit
+	// KeyFunc returns the key to limit on, by default the IP address of the client is used
+	KeyFunc RateLimitKeyFunc
+	// Skipper skips the rate limit for a request
+	Skipper middleware.Skipper
+}
+
+// RateLimitExtension is an extension that limits the amount of requests, on top of valkey or a custom RateLimitBackend
+type RateLimitExtension struct {
+	app             *Bat
+	backend         RateLimitBackend
+	logger          *Logger
+	keyPrefix       string
+	exceededHandler RateLimitExceededHandler
+}
+
+// RateLimitExtensionOption is a function that modifies the RateLimitExtension
+type RateLimitExtensionOption func(*RateLimitExtension) error
+
+// WithRateLimitBackend sets the backend of the rate limiter, by default the client of the ValkeyExtension is used
+func WithRateLimitBackend(backend RateLimitBackend) RateLimitExtensionOption {
+	return func(r *RateLimitExtension) error {
+		r.backend = backend
+		return nil
+	}
+}
+
+// WithRateLimitKeyPrefix sets the prefix of all rate limit keys
+func WithRateLimitKeyPrefix(prefix string) RateLimitExtensionOption {
+	return func(r *RateLimitExtension) error {
+		r.keyPrefix = prefix
+		return nil
+	}
+}
+
+// WithRateLimitExceededHandler sets the handler for requests that exceeded the rate limit, the RateLimit headers are
+// already set when it is called
+func WithRateLimitExceededHandler(handler RateLimitExceededHandler) RateLimitExtensionOption {
+	return func(r *RateLimitExtension) error {
+		r.exceededHandler = handler
+		return nil
+	}
+}
+
+// NewRateLimitExtension creates a new rate limit extension
+func NewRateLimitExtension(opts ...RateLimitExtensionOption) (*RateLimitExtension, error) {
+	ext := &RateLimitExtension{
+		keyPrefix: DefaultRateLimitKeyPrefix,
+	}
+	ext.exceededHandler = ext.defaultExceededHandler
+
+	for _, opt := range opts {
+		err := opt(ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ext, nil
+}
+
+// Register registers the rate limit extension
+func (r *RateLimitExtension) Register(app *Bat) error {
+	r.app = app
+	r.logger = &Logger{app.Logger.With("module", "rate_limit_extension")}
+	if r.backend == nil {
+		r.backend = NewValkeyRateLimitBackend(GetExtension[*ValkeyExtension](app).GetClient())
+	}
+	return nil
+}
+
+// Requirements returns the requirements for the rate limit extension
+func (r *RateLimitExtension) Requirements() []reflect.Type {
+	if r.backend == nil {
+		return []reflect.Type{
+			reflect.TypeOf(ValkeyExtension{}),
+		}
+	}
+	return []reflect.Type{}
+}
+
+// Backend returns the backend of the rate limiter
+func (r *RateLimitExtension) Backend() RateLimitBackend {
+	return r.backend
+}
+
+// Middleware returns a middleware that limits the requests according to the rule, use it on a route group or a single
+// route to configure different limits per group
+func (r *RateLimitExtension) Middleware(rule RateLimitRule) echo.MiddlewareFunc {
+	if rule.KeyFunc == nil {
+		rule.KeyFunc = RateLimitByIP
+	}
+	if rule.Skipper == nil {
+		rule.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if rule.Skipper(c) {
+				return next(c)
+			}
+
+			key, err := rule.KeyFunc(c)
+			if err != nil {
+				return err
+			}
+
+			result, err := r.backend.Allow(c.Request().Context(), r.keyPrefix+rule.Name+":"+key, rule.RateLimit)
+			if err != nil {
+				r.logger.Error("Failed to check rate limit", slog.String("rule", rule.Name), slog.Any("err", err))
+				return err
+			}
+
+			setRateLimitHeaders(c.Response().Header(), rule.RateLimit, result)
+			if result.Allowed {
+				return next(c)
+			}
+
+			r.logger.Debug("Rate limit exceeded", slog.String("rule", rule.Name), slog.String("key", key))
+			c.Response().Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			return r.exceededHandler(c, rule, result)
+		}
+	}
+}
+
+// defaultExceededHandler redirects Inertia form submissions back with a flashed error, so the page can show it like any
+// other validation error. All other requests get a 429 error.
+func (r *RateLimitExtension) defaultExceededHandler(c echo.Context, _ RateLimitRule, result RateLimitResult) error {
+	message := fmt.Sprintf("Too many requests, please try again in %d seconds.", ceilSeconds(result.RetryAfter))
+
+	req := c.Request()
+	referer := req.Referer()
+	flashExtension, ok := LookupExtension[*FlashExtension](r.app)
+	// Redirecting a GET request back could loop, so only form submissions are redirected
+	if ok && req.Header.Get("X-Inertia") != "" && req.Method != http.MethodGet && referer != "" {
+		err := flashExtension.FlashErrors(req.Context(), gonertia.ValidationErrors{RateLimitErrorKey: message})
+		if err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, referer)
+	}
+
+	return echo.NewHTTPError(http.StatusTooManyRequests, message)
+}
+
+// setRateLimitHeaders sets the RateLimit headers of the IETF RateLimit header fields draft
+func setRateLimitHeaders(header http.Header, limit RateLimit, result RateLimitResult) {
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// RateLimitByIP limits the requests per IP address
+func RateLimitByIP(c echo.Context) (string, error) {
+	return "ip:" + c.RealIP(), nil
+}
+
+// RateLimitBySession limits the requests per session, requests without a session are limited per IP address
+func RateLimitBySession(s *SessionExtension) RateLimitKeyFunc {
+	return func(c echo.Context) (string, error) {
+		sessionID, ok := c.Request().Context().Value(s.sessionKey).(string)
+		if !ok || sessionID == "" {
+			return RateLimitByIP(c)
+		}
+		return "session:" + sessionID, nil
+	}
+}
+
+// RateLimitByUser limits the requests per user, userID returns false when the request is not authenticated, those
+// requests are limited per IP address
+func RateLimitByUser(userID func(c echo.Context) (string, bool)) RateLimitKeyFunc {
+	return func(c echo.Context) (string, error) {
+		id, ok := userID(c)
+		if !ok {
+			return RateLimitByIP(c)
+		}
+		return "user:" + id, nil
+	}
+}
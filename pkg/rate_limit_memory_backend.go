@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval is the time between removals of expired counters
+const rateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitBackend is a RateLimitBackend that stores the counters in memory, it is meant for tests and single
+// instance apps
+type MemoryRateLimitBackend struct {
+	mu        sync.Mutex
+	windows   map[string]*memorySlidingWindow
+	buckets   map[string]*memoryTokenBucket
+	lastSweep time.Time
+}
+
+type memorySlidingWindow struct {
+	index     int64
+	previous  int64
+	current   int64
+	expiresAt time.Time
+}
+
+type memoryTokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+// NewMemoryRateLimitBackend creates a new in-memory rate limit backend
+func NewMemoryRateLimitBackend() *MemoryRateLimitBackend {
+	return &MemoryRateLimitBackend{
+		windows:   make(map[string]*memorySlidingWindow),
+		buckets:   make(map[string]*memoryTokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow counts a request for the key and returns whether it is allowed by the limit
+func (m *MemoryRateLimitBackend) Allow(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	if err := limit.validate(); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	if limit.Algorithm == RateLimitTokenBucket {
+		return m.tokenBucket(now, key, limit), nil
+	}
+	return m.slidingWindow(now, key, limit), nil
+}
+
+func (m *MemoryRateLimitBackend) slidingWindow(now time.Time, key string, limit RateLimit) RateLimitResult {
+	window := limit.Period.Milliseconds()
+	ms := now.UnixMilli()
+	index := ms / window
+	elapsed := time.Duration(ms%window) * time.Millisecond
+
+	w, ok := m.windows[key]
+	if !ok {
+		w = &memorySlidingWindow{index: index}
+		m.windows[key] = w
+	}
+	// Move the counts along when a new window started
+	switch w.index {
+	case index:
+	case index - 1:
+		w.previous, w.current = w.current, 0
+	default:
+		w.previous, w.current = 0, 0
+	}
+	w.index = index
+
+	left := limit.Period - elapsed
+	allowed := float64(w.previous)*float64(left)/float64(limit.Period)+float64(w.current) < float64(limit.Requests)
+	if allowed {
+		w.current++
+		w.expiresAt = now.Add(2 * limit.Period)
+	}
+	return slidingWindowResult(limit, allowed, w.previous, w.current, elapsed)
+}
+
+func (m *MemoryRateLimitBackend) tokenBucket(now time.Time, key string, limit RateLimit) RateLimitResult {
+	capacity := float64(limit.Requests)
+	interval := float64(limit.Period) / capacity
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryTokenBucket{tokens: capacity, updatedAt: now}
+		m.buckets[key] = b
+	}
+	b.tokens = min(capacity, b.tokens+float64(now.Sub(b.updatedAt))/interval)
+	b.updatedAt = now
+	b.expiresAt = now.Add(limit.Period)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return tokenBucketResult(limit, allowed, b.tokens)
+}
+
+// sweep removes the expired counters, so keys that are not used anymore do not fill the memory
+func (m *MemoryRateLimitBackend) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, w := range m.windows {
+		if now.After(w.expiresAt) {
+			delete(m.windows, key)
+		}
+	}
+	for key, b := range m.buckets {
+		if now.After(b.expiresAt) {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"log/slog"
+	"math/rand/v2"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultSchedulerKeyPrefix = "scheduler:"
+const DefaultScheduleMaxDuration = time.Hour
+
+var ScheduleExistsError = errors.New("a task with this name is already scheduled")
+
+// ScheduledTask is a function that is run by the scheduler
+type ScheduledTask func(ctx context.Context) error
+
+// ScheduleOption is a function that modifies a scheduled task
+type ScheduleOption func(*scheduledTask)
+
+// ScheduleJitter delays every run by a random duration up to jitter, so tasks with the same schedule do not all run at
+// the same moment
+func ScheduleJitter(jitter time.Duration) ScheduleOption {
+	return func(t *scheduledTask) {
+		t.jitter = jitter
+	}
+}
+
+// ScheduleMaxDuration sets how long a run may take, the context of the task is cancelled after it. A new run is
+// skipped while the previous run is still running, for at most this duration.
+func ScheduleMaxDuration(maxDuration time.Duration) ScheduleOption {
+	return func(t *scheduledTask) {
+		t.maxDuration = maxDuration
+	}
+}
+
+type scheduledTask struct {
+	name        string
+	schedule    cron.Schedule
+	task        ScheduledTask
+	jitter      time.Duration
+	maxDuration time.Duration
+	// running prevents overlapping runs in this process, the running lock prevents it between processes
+	running atomic.Bool
+}
+
+// SchedulerExtension is an extension that runs tasks on cron schedules, when the app runs with multiple instances only
+// one instance runs each tick
+type SchedulerExtension struct {
+	locker    Locker
+	logger    *Logger
+	keyPrefix string
+	location  *time.Location
+	parser    cron.Parser
+	mu        sync.Mutex
+	tasks     map[string]*scheduledTask
+	// ctx is cancelled on shutdown to stop scheduling new runs
+	ctx    context.Context
+	cancel context.CancelFunc
+	// taskCtx is cancelled when the running tasks did not finish before the shutdown timeout
+	taskCtx     context.Context
+	cancelTasks context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// SchedulerExtensionOption is a function that modifies the SchedulerExtension
+type SchedulerExtensionOption func(*SchedulerExtension) error
+
+// WithSchedulerLocker sets the locker that makes sure only one instance runs a tick, by default the client of the
+// ValkeyExtension is used
+func WithSchedulerLocker(locker Locker) SchedulerExtensionOption {
+	return func(s *SchedulerExtension) error {
+		s.locker = locker
+		return nil
+	}
+}
+
+// WithSchedulerKeyPrefix sets the prefix of all lock keys
+func WithSchedulerKeyPrefix(prefix string) SchedulerExtensionOption {
+	return func(s *SchedulerExtension) error {
+		s.keyPrefix = prefix
+		return nil
+	}
+}
+
+// WithSchedulerLocation sets the time zone the schedules are interpreted in, by default the local time zone is used
+func WithSchedulerLocation(location *time.Location) SchedulerExtensionOption {
+	return func(s *SchedulerExtension) error {
+		s.location = location
+		return nil
+	}
+}
+
+// NewSchedulerExtension creates a new scheduler extension
+func NewSchedulerExtension(opts ...SchedulerExtensionOption) (*SchedulerExtension, error) {
+	ext := &SchedulerExtension{
+		keyPrefix: DefaultSchedulerKeyPrefix,
+		location:  time.Local,
+		// Standard cron expressions, optionally with seconds, and descriptors like @hourly and @every 5m
+		parser: cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor),
+		tasks:  make(map[string]*scheduledTask),
+	}
+
+	for _, opt := range opts {
+		err := opt(ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ext, nil
+}
+
+// Register registers the scheduler extension, the scheduler runs until the app shuts down
+func (s *SchedulerExtension) Register(app *Bat) error {
+	s.logger = &Logger{app.Logger.With("module", "scheduler_extension")}
+	if s.locker == nil {
+		s.locker = NewValkeyLocker(GetExtension[*ValkeyExtension](app).GetClient())
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.taskCtx, s.cancelTasks = context.WithCancel(context.Background())
+	app.OnShutdown(s.stop)
+	return nil
+}
+
+// Requirements returns the requirements for the scheduler extension
+func (s *SchedulerExtension) Requirements() []reflect.Type {
+	if s.locker == nil {
+		return []reflect.Type{
+			reflect.TypeOf(ValkeyExtension{}),
+		}
+	}
+	return []reflect.Type{}
+}
+
+// Schedule runs the task on the cron schedule, the name identifies the task between instances so it must be unique.
+// The spec is a cron expression with an optional seconds field, or a descriptor like @daily or @every 10m.
+func (s *SchedulerExtension) Schedule(name, spec string, task ScheduledTask, opts ...ScheduleOption) error {
+	schedule, err := s.parser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for task %s: %w", spec, name, err)
+	}
+	if delay, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		schedule = alignedDelaySchedule{delay: delay.Delay}
+	}
+
+	t := &scheduledTask{
+		name:        name,
+		schedule:    schedule,
+		task:        task,
+		maxDuration: DefaultScheduleMaxDuration,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[name]; ok {
+		return ScheduleExistsError
+	}
+	s.tasks[name] = t
+
+	s.logger.Debug("Scheduled task", slog.String("task", name), slog.String("schedule", spec))
+	s.wg.Add(1)
+	go s.loop(t)
+	return nil
+}
+
+// alignedDelaySchedule runs every delay, aligned to the zero time instead of the start of the app, so all instances
+// compute the same ticks
+type alignedDelaySchedule struct {
+	delay time.Duration
+}
+
+// Next returns the next multiple of the delay after t
+func (a alignedDelaySchedule) Next(t time.Time) time.Time {
+	return t.Truncate(a.delay).Add(a.delay)
+}
+
+// loop waits for every tick of the task and runs it
+func (s *SchedulerExtension) loop(t *scheduledTask) {
+	defer s.wg.Done()
+	for {
+		next := t.schedule.Next(time.Now().In(s.location))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.wg.Add(1)
+		go s.tick(t, next)
+	}
+}
+
+// tick runs the task for the tick at the scheduled time, unless another instance already runs this tick or the
+// previous run did not finish yet
+func (s *SchedulerExtension) tick(t *scheduledTask, scheduled time.Time) {
+	defer s.wg.Done()
+	logger := s.logger.With(slog.String("task", t.name), slog.Time("scheduled", scheduled))
+
+	if t.jitter > 0 {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(rand.N(t.jitter)):
+		}
+	}
+
+	if !t.running.CompareAndSwap(false, true) {
+		logger.Warn("Skipping task, the previous run is still running")
+		return
+	}
+	defer t.running.Store(false)
+
+	// The tick lock is kept until the next tick, so instances with a slightly different clock do not run it again
+	tickTTL := max(time.Until(t.schedule.Next(scheduled)), time.Second)
+	_, acquired, err := s.locker.Lock(s.taskCtx, s.keyPrefix+t.name+":tick:"+strconv.FormatInt(scheduled.Unix(), 10), tickTTL)
+	if err != nil {
+		logger.Error("Failed to acquire tick lock", slog.Any("err", err))
+		return
+	}
+	if !acquired {
+		logger.Debug("Skipping task, another instance runs this tick")
+		return
+	}
+
+	unlock, acquired, err := s.locker.Lock(s.taskCtx, s.keyPrefix+t.name+":running", t.maxDuration)
+	if err != nil {
+		logger.Error("Failed to acquire running lock", slog.Any("err", err))
+		return
+	}
+	if !acquired {
+		logger.Warn("Skipping task, the previous run is still running on another instance")
+		return
+	}
+	defer func() {
+		if err := unlock(context.Background()); err != nil {
+			logger.Error("Failed to release running lock", slog.Any("err", err))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(s.taskCtx, t.maxDuration)
+	defer cancel()
+
+	logger.Info("Running task")
+	start := time.Now()
+	err = runScheduledTask(ctx, t.task)
+	if err != nil {
+		logger.Error("Task failed", slog.Duration("duration", time.Since(start)), slog.Any("err", err))
+		return
+	}
+	logger.Info("Task finished", slog.Duration("duration", time.Since(start)))
+}
+
+// runScheduledTask runs the task, a panic is returned as an error so it does not crash the app
+func runScheduledTask(ctx context.Context, task ScheduledTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+	return task(ctx)
+}
+
+// stop stops scheduling new runs and waits for the running tasks, they are cancelled when ctx is done before they finish
+func (s *SchedulerExtension) stop(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancelTasks()
+		return ctx.Err()
+	}
+}
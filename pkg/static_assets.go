@@ -0,0 +1,201 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/labstack/echo/v4"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHashedAssetPattern matches the file names vite generates with a content hash in the assets directory
+// (assets/index-BRBmoGS9.js), files copied from the public directory are not in it
+var DefaultHashedAssetPattern = regexp.MustCompile(`^assets/[^/]+-[A-Za-z0-9_-]{8}\.[A-Za-z0-9]+$`)
+
+const (
+	immutableCacheControl  = "public, max-age=31536000, immutable"
+	revalidateCacheControl = "public, max-age=0, must-revalidate"
+)
+
+// precompressedVariants are the encodings that can be served from precompressed files, in order of preference
+var precompressedVariants = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// staticAssets serves files from a file system with cache headers, and serves precompressed variants when the client
+// accepts them
+type staticAssets struct {
+	fsys fs.FS
+	// hashedFiles are the output files of the vite manifest, vite names them with a content hash
+	hashedFiles   map[string]bool
+	hashedPattern *regexp.Regexp
+	// startedAt is used as the Last-Modified date for files without a modification time, like the ones in an embed.FS
+	startedAt time.Time
+	// etags caches the ETag per served file, the files can not change because they are embedded
+	etags sync.Map
+}
+
+func newStaticAssets(fsys fs.FS, manifest ViteManifest, hashedPattern *regexp.Regexp) *staticAssets {
+	hashedFiles := make(map[string]bool)
+	for _, file := range manifest.Files() {
+		hashedFiles[file] = true
+	}
+	return &staticAssets{
+		fsys:          fsys,
+		hashedFiles:   hashedFiles,
+		hashedPattern: hashedPattern,
+		startedAt:     time.Now(),
+	}
+}
+
+// Handler returns the echo handler, the route must have a "*" wildcard parameter containing the file path
+func (s *staticAssets) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := strings.TrimPrefix(path.Clean("/"+c.Param("*")), "/")
+		if name == "" || !fs.ValidPath(name) {
+			return echo.ErrNotFound
+		}
+
+		file, encoding, err := s.open(name, c.Request().Header.Get(echo.HeaderAcceptEncoding))
+		if err != nil {
+			return echo.ErrNotFound
+		}
+		defer file.Close()
+
+		stat, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if stat.IsDir() {
+			return echo.ErrNotFound
+		}
+
+		content, err := readSeeker(file)
+		if err != nil {
+			return err
+		}
+
+		etag, err := s.etag(name, encoding, content)
+		if err != nil {
+			return err
+		}
+
+		header := c.Response().Header()
+		header.Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		header.Set("ETag", etag)
+		header.Set(echo.HeaderContentType, contentType(name))
+		if encoding != "" {
+			header.Set(echo.HeaderContentEncoding, encoding)
+		}
+		if s.isHashed(name) {
+			header.Set(echo.HeaderCacheControl, immutableCacheControl)
+		} else {
+			header.Set(echo.HeaderCacheControl, revalidateCacheControl)
+		}
+
+		modTime := stat.ModTime()
+		if modTime.IsZero() {
+			modTime = s.startedAt
+		}
+
+		http.ServeContent(c.Response(), c.Request(), name, modTime, content)
+		return nil
+	}
+}
+
+// isHashed returns true if the name of the file contains a content hash, so its content never changes
+func (s *staticAssets) isHashed(name string) bool {
+	if s.hashedFiles[name] {
+		return true
+	}
+	return s.hashedPattern != nil && s.hashedPattern.MatchString(name)
+}
+
+// open opens the best variant of the file for the accepted encodings, it returns the encoding of the opened file
+func (s *staticAssets) open(name, acceptEncoding string) (fs.File, string, error) {
+	for _, variant := range precompressedVariants {
+		if !acceptsEncoding(acceptEncoding, variant.encoding) {
+			continue
+		}
+		file, err := s.fsys.Open(name + variant.ext)
+		if err == nil {
+			return file, variant.encoding, nil
+		}
+	}
+
+	file, err := s.fsys.Open(name)
+	return file, "", err
+}
+
+// etag returns the strong ETag of the file variant, it is computed from the content
+func (s *staticAssets) etag(name, encoding string, content io.ReadSeeker) (string, error) {
+	key := name + ":" + encoding
+	if etag, ok := s.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+
+	hash := sha256.New()
+	_, err := io.Copy(hash, content)
+	if err != nil {
+		return "", err
+	}
+	_, err = content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	etag := strconv.Quote(hex.EncodeToString(hash.Sum(nil)[:16]))
+	s.etags.Store(key, etag)
+	return etag, nil
+}
+
+// acceptsEncoding returns true if the Accept-Encoding header accepts the encoding
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		// An encoding with q=0 is explicitly not accepted
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
+
+// contentType returns the content type based on the file extension
+func contentType(name string) string {
+	ct := mime.TypeByExtension(path.Ext(name))
+	if ct == "" {
+		return echo.MIMEOctetStream
+	}
+	return ct
+}
+
+// readSeeker returns the file as an io.ReadSeeker, files that can not seek are read into memory
+func readSeeker(file fs.File) (io.ReadSeeker, error) {
+	if rs, ok := file.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
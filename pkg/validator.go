@@ -0,0 +1,287 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/romsar/gonertia/v2"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	UnknownValidationRuleError = errors.New("unknown validation rule")
+	InvalidValidationRuleError = errors.New("invalid validation rule")
+	ValidatorNoDatabaseError   = errors.New("unique rule needs a validator with a database")
+)
+
+// identifierRegex matches the table and column names of unique rules, they are written into the query
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// UniqueIgnorer is implemented by structs that update a row, the unique rules do not count the row with the ignored ID
+type UniqueIgnorer interface {
+	IgnoredID() int64
+}
+
+// Validatable is implemented by requests with a Validate method, like the generated ones. BindAndValidate calls it
+// instead of validating the request itself, so requests can add their own checks to the rules of their tags.
+type Validatable interface {
+	Validate(ctx context.Context, validator *Validator) (gonertia.ValidationErrors, error)
+}
+
+// Validator validates structs with the rules in their validate tags, like `validate:"required,email,max=255"`.
+//
+// The rules are:
+//   - required: the value is not empty, strings only containing spaces are empty
+//   - email: the value is an email address
+//   - min=n and max=n: the length of strings and slices, or the value of numbers is at least or at most n
+//   - unique=table or unique=table.column: no row of the table has the value, the column defaults to the field name
+//
+// The rules except required skip empty values. Unique rules need a validator with a database, tests without one can
+// skip them with WithValidatorSkipUnique. Errors are keyed by the json tag of the field.
+type Validator struct {
+	db         *DatabaseExtension
+	skipUnique bool
+}
+
+// ValidatorOption is a function that modifies the Validator
+type ValidatorOption func(*Validator) error
+
+// WithValidatorDatabase sets the database the unique rules query
+func WithValidatorDatabase(db *DatabaseExtension) ValidatorOption {
+	return func(v *Validator) error {
+		v.db = db
+		return nil
+	}
+}
+
+// WithValidatorSkipUnique lets unique rules pass without querying the database, for tests without one
+func WithValidatorSkipUnique() ValidatorOption {
+	return func(v *Validator) error {
+		v.skipUnique = true
+		return nil
+	}
+}
+
+// NewValidator creates a new validator
+func NewValidator(opts ...ValidatorOption) (*Validator, error) {
+	v := &Validator{}
+
+	for _, opt := range opts {
+		err := opt(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// validationRule is a parsed rule of a validate tag
+type validationRule struct {
+	name  string
+	param string
+}
+
+// Validate validates the struct s points to and returns the first failed rule of every field, the errors are empty when
+// s is valid. An error is returned for invalid rules and failed unique queries.
+func (v *Validator) Validate(ctx context.Context, s any) (gonertia.ValidationErrors, error) {
+	value := reflect.ValueOf(s)
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: can only validate structs, got %s", InvalidValidationRuleError, value.Kind())
+	}
+
+	var ignoredID int64
+	if ignorer, ok := s.(UniqueIgnorer); ok {
+		ignoredID = ignorer.IgnoredID()
+	}
+
+	errs := gonertia.ValidationErrors{}
+	err := v.validateStruct(ctx, value, ignoredID, errs)
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// validateStruct adds the errors of the fields of the struct to errs, embedded structs are validated as well
+func (v *Validator) validateStruct(ctx context.Context, value reflect.Value, ignoredID int64, errs gonertia.ValidationErrors) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			err := v.validateStruct(ctx, value.Field(i), ignoredID, errs)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+
+		name := fieldName(field)
+		for _, part := range strings.Split(tag, ",") {
+			rule, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+			message, err := v.check(ctx, validationRule{name: rule, param: param}, name, value.Field(i), ignoredID)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			if message != "" {
+				errs[name] = message
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name of the field in the errors, the name of its json tag or the name of the field
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// fieldLabel returns the name of the field for people, like Published at for published_at
+func fieldLabel(name string) string {
+	label := strings.ReplaceAll(name, "_", " ")
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// check returns the message of the failed rule, or an empty string when the value passes it
+func (v *Validator) check(ctx context.Context, rule validationRule, name string, value reflect.Value, ignoredID int64) (string, error) {
+	label := fieldLabel(name)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			break
+		}
+		value = value.Elem()
+	}
+	empty := isEmptyValue(value)
+
+	switch rule.name {
+	case "required":
+		if empty {
+			return label + " is required", nil
+		}
+		return "", nil
+	case "email":
+		if empty {
+			return "", nil
+		}
+		if value.Kind() != reflect.String {
+			return "", fmt.Errorf("%w: email needs a string", InvalidValidationRuleError)
+		}
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Address != value.String() {
+			return label + " must be a valid email address", nil
+		}
+		return "", nil
+	case "min", "max":
+		if empty {
+			return "", nil
+		}
+		return checkBound(rule, label, value)
+	case "unique":
+		if empty {
+			return "", nil
+		}
+		return v.checkUnique(ctx, rule, name, label, value, ignoredID)
+	default:
+		return "", fmt.Errorf("%w: %s", UnknownValidationRuleError, rule.name)
+	}
+}
+
+// isEmptyValue returns whether the value is nil, zero or a string of only spaces
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return value.IsNil()
+	default:
+		return value.IsZero()
+	}
+}
+
+// checkBound checks the min or max rule, strings are counted in characters
+func checkBound(rule validationRule, label string, value reflect.Value) (string, error) {
+	bound, err := strconv.ParseFloat(rule.param, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s needs a number, got %q", InvalidValidationRuleError, rule.name, rule.param)
+	}
+
+	var actual float64
+	var unit string
+	switch value.Kind() {
+	case reflect.String:
+		actual = float64(utf8.RuneCountInString(value.String()))
+		unit = " characters"
+	case reflect.Slice, reflect.Map:
+		actual = float64(value.Len())
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	default:
+		return "", fmt.Errorf("%w: %s can not check a %s", InvalidValidationRuleError, rule.name, value.Kind())
+	}
+
+	if rule.name == "min" && actual < bound {
+		return fmt.Sprintf("%s must be at least %s%s", label, rule.param, unit), nil
+	}
+	if rule.name == "max" && actual > bound {
+		return fmt.Sprintf("%s must be at most %s%s", label, rule.param, unit), nil
+	}
+	return "", nil
+}
+
+// checkUnique checks the unique rule, the query takes part in the transaction of ctx
+func (v *Validator) checkUnique(ctx context.Context, rule validationRule, name, label string, value reflect.Value, ignoredID int64) (string, error) {
+	table, column, ok := strings.Cut(rule.param, ".")
+	if !ok {
+		column = name
+	}
+	if !identifierRegex.MatchString(table) || !identifierRegex.MatchString(column) {
+		return "", fmt.Errorf("%w: unique needs a table and column name, got %q", InvalidValidationRuleError, rule.param)
+	}
+	if v.skipUnique {
+		return "", nil
+	}
+	if v.db == nil {
+		return "", ValidatorNoDatabaseError
+	}
+
+	query := "SELECT COUNT(*) FROM " + table + " WHERE " + column + " = ?"
+	args := []any{value.Interface()}
+	if ignoredID != 0 {
+		query += " AND id <> ?"
+		args = append(args, ignoredID)
+	}
+
+	conn := v.db.Conn(ctx)
+	var count int
+	err := conn.GetContext(ctx, &count, conn.Rebind(query), args...)
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return label + " is already taken", nil
+	}
+	return "", nil
+}
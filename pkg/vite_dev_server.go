@@ -0,0 +1,234 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+var DevServerStoppedError = errors.New("dev server is stopped")
+
+// maxDevServerRestartDelay is the longest the supervisor waits before restarting a dev server that keeps crashing, a
+// dev server that ran this long resets the delay
+const maxDevServerRestartDelay = 30 * time.Second
+
+// viteDevServer supervises the vite dev server child process, it restarts the process when it crashes and pipes its
+// output into the logger
+type viteDevServer struct {
+	logger       *Logger
+	jsRuntime    string
+	dir          string
+	url          string
+	readyTimeout time.Duration
+	// restartDelay is the delay before the first restart, it doubles every time the dev server crashes again
+	restartDelay time.Duration
+	client       *http.Client
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	startedAt time.Time
+	// exited is closed when the process of cmd exits
+	exited  chan struct{}
+	ready   chan struct{}
+	stopped bool
+	// stop is closed by Stop, so a waiting restart is canceled
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newViteDevServer(logger *Logger, jsRuntime, dir, url string, readyTimeout, restartDelay time.Duration) *viteDevServer {
+	return &viteDevServer{
+		logger:       logger,
+		jsRuntime:    jsRuntime,
+		dir:          dir,
+		url:          url,
+		readyTimeout: readyTimeout,
+		restartDelay: restartDelay,
+		client:       &http.Client{Timeout: time.Second},
+		ready:        make(chan struct{}),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start starts the dev server and supervises it until Stop is called
+func (v *viteDevServer) Start() error {
+	err := v.spawn()
+	if err != nil {
+		close(v.done)
+		return err
+	}
+	go v.supervise()
+	return nil
+}
+
+// spawn starts a new dev server process
+func (v *viteDevServer) spawn() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.stopped {
+		return DevServerStoppedError
+	}
+
+	cmd := exec.Command(v.jsRuntime, "run", "dev")
+	cmd.Dir = v.dir
+	setProcessGroup(cmd)
+	cmd.Stdout = &logWriter{logger: v.logger, level: slog.LevelInfo}
+	cmd.Stderr = &logWriter{logger: v.logger, level: slog.LevelWarn}
+
+	v.logger.Info("Starting the dev server", slog.String("runtime", v.jsRuntime), slog.String("dir", v.dir))
+	err := cmd.Start()
+	if err != nil {
+		v.logger.Error("Failed to start the dev server", slog.Any("err", err))
+		return err
+	}
+	v.cmd = cmd
+	v.startedAt = time.Now()
+	v.exited = make(chan struct{})
+
+	go v.waitUntilReady(v.ready, v.exited)
+	return nil
+}
+
+// supervise waits for the process to exit and restarts it, unless the dev server is stopped. The delay before a restart
+// doubles up to maxDevServerRestartDelay while the dev server keeps crashing.
+func (v *viteDevServer) supervise() {
+	defer close(v.done)
+	delay := v.restartDelay
+	for {
+		v.mu.Lock()
+		cmd := v.cmd
+		exited := v.exited
+		v.mu.Unlock()
+
+		err := cmd.Wait()
+		close(exited)
+
+		v.mu.Lock()
+		if v.stopped {
+			v.mu.Unlock()
+			v.logger.Info("Dev server stopped")
+			return
+		}
+		if time.Since(v.startedAt) >= maxDevServerRestartDelay {
+			delay = v.restartDelay
+		}
+		v.ready = make(chan struct{})
+		v.mu.Unlock()
+
+		v.logger.Error("Dev server exited unexpectedly, restarting", slog.Any("err", err), slog.Duration("delay", delay))
+		for {
+			select {
+			case <-time.After(delay):
+			case <-v.stop:
+				return
+			}
+			delay = min(delay*2, maxDevServerRestartDelay)
+			err = v.spawn()
+			if err == nil {
+				break
+			}
+			if errors.Is(err, DevServerStoppedError) {
+				return
+			}
+			v.logger.Error("Failed to restart the dev server, retrying", slog.Any("err", err), slog.Duration("delay", delay))
+		}
+	}
+}
+
+// waitUntilReady polls the dev server until it responds and then closes the ready channel, it stops when the process
+// exits. A dev server that is not ready after the ready timeout is logged once, a cold start can take longer.
+func (v *viteDevServer) waitUntilReady(ready, exited chan struct{}) {
+	deadline := time.Now().Add(v.readyTimeout)
+	logged := false
+	for {
+		resp, err := v.client.Get(v.url)
+		if err == nil {
+			resp.Body.Close()
+			v.logger.Info("Dev server is ready", slog.String("url", v.url))
+			close(ready)
+			return
+		}
+		if !logged && time.Now().After(deadline) {
+			v.logger.Warn("Dev server did not become ready in time, still waiting", slog.String("url", v.url), slog.Duration("timeout", v.readyTimeout))
+			logged = true
+		}
+
+		select {
+		case <-exited:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// WaitReady blocks until the dev server is ready or the context is done
+func (v *viteDevServer) WaitReady(ctx context.Context) error {
+	v.mu.Lock()
+	ready := v.ready
+	v.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop terminates the dev server and waits for it to exit
+func (v *viteDevServer) Stop(ctx context.Context) error {
+	v.mu.Lock()
+	if !v.stopped {
+		v.stopped = true
+		close(v.stop)
+	}
+	cmd := v.cmd
+	v.mu.Unlock()
+
+	if cmd != nil && cmd.Process != nil {
+		v.logger.Info("Stopping the dev server")
+		err := terminateProcess(cmd)
+		if err != nil {
+			v.logger.Error("Failed to stop the dev server", slog.Any("err", err))
+		}
+	}
+
+	select {
+	case <-v.done:
+		return nil
+	case <-ctx.Done():
+		if cmd != nil && cmd.Process != nil {
+			_ = killProcess(cmd)
+		}
+		return ctx.Err()
+	}
+}
+
+// logWriter is an io.Writer that writes every line to the logger
+type logWriter struct {
+	logger *Logger
+	level  slog.Level
+	buf    []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimRight(w.buf[:i], "\r")
+		if len(line) > 0 {
+			w.logger.Log(context.Background(), w.level, string(line), slog.String("source", "vite"))
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package pkg
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess kills the process tree of the command with taskkill, windows does not support sending signals and
+// killing only the process would leave vite running
+func terminateProcess(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+// killProcess kills the process tree of the command, terminateProcess already does so forcefully
+func killProcess(cmd *exec.Cmd) error {
+	return terminateProcess(cmd)
+}
@@ -0,0 +1,148 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ViteManifestEmptyError     = errors.New("vite manifest is empty")
+	ViteManifestNoEntriesError = errors.New("vite manifest does not contain any entries")
+)
+
+// ViteManifestChunk is a single chunk in the vite manifest, see https://vite.dev/guide/backend-integration
+type ViteManifestChunk struct {
+	// File is the output file of the chunk, relative to the build directory
+	File string `json:"file"`
+	// Name is the name of the chunk
+	Name string `json:"name,omitempty"`
+	// Src is the source file of the chunk, relative to the vite root
+	Src string `json:"src,omitempty"`
+	// IsEntry is true if the chunk is an entry point
+	IsEntry bool `json:"isEntry,omitempty"`
+	// IsDynamicEntry is true if the chunk is only imported dynamically
+	IsDynamicEntry bool `json:"isDynamicEntry,omitempty"`
+	// CSS is the list of stylesheets that belong to the chunk
+	CSS []string `json:"css,omitempty"`
+	// Assets is the list of static assets that belong to the chunk
+	Assets []string `json:"assets,omitempty"`
+	// Imports is the list of manifest keys that are statically imported by the chunk
+	Imports []string `json:"imports,omitempty"`
+	// DynamicImports is the list of manifest keys that are dynamically imported by the chunk
+	DynamicImports []string `json:"dynamicImports,omitempty"`
+}
+
+// ViteManifest is the parsed vite manifest, it maps the manifest key (usually the source path) to the chunk
+type ViteManifest map[string]*ViteManifestChunk
+
+// ParseViteManifest parses the given vite manifest file
+func ParseViteManifest(data []byte) (ViteManifest, error) {
+	if len(data) == 0 {
+		return nil, ViteManifestEmptyError
+	}
+
+	manifest := make(ViteManifest)
+	err := json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vite manifest: %w", err)
+	}
+
+	hasEntry := false
+	for key, chunk := range manifest {
+		if chunk == nil || chunk.File == "" {
+			return nil, fmt.Errorf("vite manifest chunk %q has no file", key)
+		}
+		for _, imp := range chunk.Imports {
+			if _, ok := manifest[imp]; !ok {
+				return nil, fmt.Errorf("vite manifest chunk %q imports unknown chunk %q", key, imp)
+			}
+		}
+		if chunk.IsEntry {
+			hasEntry = true
+		}
+	}
+
+	if !hasEntry {
+		return nil, ViteManifestNoEntriesError
+	}
+
+	return manifest, nil
+}
+
+// Chunk returns the chunk for the given manifest key
+func (m ViteManifest) Chunk(key string) (*ViteManifestChunk, error) {
+	chunk, ok := m[key]
+	if !ok {
+		return nil, fmt.Errorf("asset %q not found", key)
+	}
+	return chunk, nil
+}
+
+// Files returns the output files of all chunks, including their stylesheets and assets
+func (m ViteManifest) Files() []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, chunk := range m {
+		for _, file := range append(append([]string{chunk.File}, chunk.CSS...), chunk.Assets...) {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// ImportedChunks returns all chunks that are statically imported by the given entry, recursively and in import order.
+// The entry itself is not included.
+func (m ViteManifest) ImportedChunks(entry string) ([]*ViteManifestChunk, error) {
+	if _, err := m.Chunk(entry); err != nil {
+		return nil, err
+	}
+
+	var chunks []*ViteManifestChunk
+	seen := map[string]bool{entry: true}
+	var walk func(key string)
+	walk = func(key string) {
+		for _, imp := range m[key].Imports {
+			if seen[imp] {
+				continue
+			}
+			seen[imp] = true
+			chunks = append(chunks, m[imp])
+			walk(imp)
+		}
+	}
+	walk(entry)
+
+	return chunks, nil
+}
+
+// CSS returns all stylesheets that are needed by the given entry, including the ones of its imported chunks
+func (m ViteManifest) CSS(entry string) ([]string, error) {
+	chunk, err := m.Chunk(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := m.ImportedChunks(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var css []string
+	seen := make(map[string]bool)
+	for _, c := range append([]*ViteManifestChunk{chunk}, imported...) {
+		for _, file := range c.CSS {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			css = append(css, file)
+		}
+	}
+
+	return css, nil
+}
@@ -0,0 +1,517 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const DefaultWebSocketPath = "/ws"
+const DefaultWebSocketKeyPrefix = "ws:"
+const DefaultWebSocketSendBufferSize = 64
+const DefaultWebSocketWriteTimeout = 10 * time.Second
+const DefaultWebSocketPingInterval = 30 * time.Second
+const DefaultWebSocketMaxMessageSize = 64 * 1024
+
+var WebSocketClosedError = errors.New("websocket connection is closed")
+
+// WebSocketAuthenticator returns whether the request may open a websocket, the request always has a session
+type WebSocketAuthenticator func(c echo.Context) (bool, error)
+
+// WebSocketConnectHandler is called when a connection is opened, returning an error closes the connection
+type WebSocketConnectHandler func(conn *WebSocketConn) error
+
+// WebSocketMessageHandler is called for every message a client sends
+type WebSocketMessageHandler func(conn *WebSocketConn, message []byte)
+
+// WebSocketDisconnectHandler is called when a connection is closed
+type WebSocketDisconnectHandler func(conn *WebSocketConn)
+
+// WebSocketConn is an open websocket connection of a session
+type WebSocketConn struct {
+	// ID identifies the connection, a session can have multiple connections
+	ID        string
+	SessionID string
+	ext       *WebSocketExtension
+	conn      *websocket.Conn
+	send      chan []byte
+	// done is closed when the connection is closed
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	rooms     map[string]struct{}
+}
+
+// Send queues the message for the client, it returns an error when the connection is closed. A client that does not
+// keep up with its messages is disconnected, so a slow client can not hold back the others.
+func (w *WebSocketConn) Send(message []byte) error {
+	select {
+	case <-w.done:
+		return WebSocketClosedError
+	default:
+	}
+
+	select {
+	case w.send <- message:
+		return nil
+	default:
+		w.ext.logger.Warn("Closing slow websocket client", slog.String("conn", w.ID))
+		w.closeWith(websocket.CloseTryAgainLater, "too slow")
+		return WebSocketClosedError
+	}
+}
+
+// Join adds the connection to the room, so it receives the broadcasts to the room from all instances of the app
+func (w *WebSocketConn) Join(room string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.rooms[room]; ok {
+		return
+	}
+	w.rooms[room] = struct{}{}
+	w.ext.join(room, w)
+}
+
+// Leave removes the connection from the room
+func (w *WebSocketConn) Leave(room string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.rooms[room]; !ok {
+		return
+	}
+	delete(w.rooms, room)
+	w.ext.leave(room, w)
+}
+
+// Close closes the connection
+func (w *WebSocketConn) Close() {
+	w.closeWith(websocket.CloseNormalClosure, "")
+}
+
+func (w *WebSocketConn) closeWith(code int, reason string) {
+	w.closeOnce.Do(func() {
+		_ = w.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(w.ext.writeTimeout))
+		close(w.done)
+		_ = w.conn.Close()
+	})
+}
+
+// wsRoom is a room with at least one connection on this instance of the app
+type wsRoom struct {
+	cancel context.CancelFunc
+	conns  map[*WebSocketConn]struct{}
+}
+
+// WebSocketExtension is an extension that accepts websocket connections of sessions. Connections can join rooms, a
+// broadcast to a room reaches the connections on all instances of the app through valkey pub/sub by default.
+type WebSocketExtension struct {
+	pubSub           PubSub
+	logger           *Logger
+	sessionExtension *SessionExtension
+	upgrader         websocket.Upgrader
+	path             string
+	keyPrefix        string
+	sendBufferSize   int
+	writeTimeout     time.Duration
+	pingInterval     time.Duration
+	maxMessageSize   int64
+	authenticator    WebSocketAuthenticator
+	onConnect        WebSocketConnectHandler
+	onMessage        WebSocketMessageHandler
+	onDisconnect     WebSocketDisconnectHandler
+	mu               sync.Mutex
+	rooms            map[string]*wsRoom
+	conns            map[*WebSocketConn]struct{}
+	ctx              context.Context
+	cancel           context.CancelFunc
+}
+
+// WebSocketExtensionOption is a function that modifies the WebSocketExtension
+type WebSocketExtensionOption func(*WebSocketExtension) error
+
+// WithWebSocketPubSub sets the pub/sub used for the rooms, by default the client of the ValkeyExtension is used
+func WithWebSocketPubSub(pubSub PubSub) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.pubSub = pubSub
+		return nil
+	}
+}
+
+// WithWebSocketPath sets the path of the websocket endpoint
+func WithWebSocketPath(path string) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.path = path
+		return nil
+	}
+}
+
+// WithWebSocketKeyPrefix sets the prefix of all pub/sub channels
+func WithWebSocketKeyPrefix(prefix string) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.keyPrefix = prefix
+		return nil
+	}
+}
+
+// WithWebSocketSendBufferSize sets how many messages are queued per connection, a connection with a full queue is closed
+func WithWebSocketSendBufferSize(size int) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.sendBufferSize = size
+		return nil
+	}
+}
+
+// WithWebSocketWriteTimeout sets how long writing a message may take before the connection is closed
+func WithWebSocketWriteTimeout(timeout time.Duration) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.writeTimeout = timeout
+		return nil
+	}
+}
+
+// WithWebSocketPingInterval sets the interval of the pings, a connection that does not answer within two intervals is
+// closed
+func WithWebSocketPingInterval(interval time.Duration) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.pingInterval = interval
+		return nil
+	}
+}
+
+// WithWebSocketMaxMessageSize sets the maximum size of a message sent by a client
+func WithWebSocketMaxMessageSize(size int64) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.maxMessageSize = size
+		return nil
+	}
+}
+
+// WithWebSocketCheckOrigin sets the origin check of the upgrade, by default only same origin requests are allowed
+func WithWebSocketCheckOrigin(checkOrigin func(r *http.Request) bool) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.upgrader.CheckOrigin = checkOrigin
+		return nil
+	}
+}
+
+// WithWebSocketAuthenticator sets the authenticator of the connections, without it every session may connect
+func WithWebSocketAuthenticator(authenticator WebSocketAuthenticator) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.authenticator = authenticator
+		return nil
+	}
+}
+
+// WithWebSocketOnConnect sets the handler that is called when a connection is opened
+func WithWebSocketOnConnect(handler WebSocketConnectHandler) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.onConnect = handler
+		return nil
+	}
+}
+
+// WithWebSocketMessageHandler sets the handler of the messages sent by the clients
+func WithWebSocketMessageHandler(handler WebSocketMessageHandler) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.onMessage = handler
+		return nil
+	}
+}
+
+// WithWebSocketOnDisconnect sets the handler that is called when a connection is closed
+func WithWebSocketOnDisconnect(handler WebSocketDisconnectHandler) WebSocketExtensionOption {
+	return func(w *WebSocketExtension) error {
+		w.onDisconnect = handler
+		return nil
+	}
+}
+
+// NewWebSocketExtension creates a new websocket extension
+func NewWebSocketExtension(opts ...WebSocketExtensionOption) (*WebSocketExtension, error) {
+	ext := &WebSocketExtension{
+		path:           DefaultWebSocketPath,
+		keyPrefix:      DefaultWebSocketKeyPrefix,
+		sendBufferSize: DefaultWebSocketSendBufferSize,
+		writeTimeout:   DefaultWebSocketWriteTimeout,
+		pingInterval:   DefaultWebSocketPingInterval,
+		maxMessageSize: DefaultWebSocketMaxMessageSize,
+		rooms:          make(map[string]*wsRoom),
+		conns:          make(map[*WebSocketConn]struct{}),
+	}
+
+	for _, opt := range opts {
+		err := opt(ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ext, nil
+}
+
+// Register registers the websocket extension and the websocket endpoint
+func (w *WebSocketExtension) Register(app *Bat) error {
+	w.logger = &Logger{app.Logger.With("module", "websocket_extension")}
+	w.sessionExtension = GetExtension[*SessionExtension](app)
+	if w.pubSub == nil {
+		w.pubSub = NewValkeyPubSub(GetExtension[*ValkeyExtension](app).GetClient())
+	}
+
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	// Hijacked connections are not closed by the server, so they are closed as soon as the server starts shutting down
+	app.Echo.Server.RegisterOnShutdown(w.closeAll)
+	app.OnShutdown(func(context.Context) error {
+		w.closeAll()
+		return nil
+	})
+
+	app.GET(w.path, w.handle)
+	return nil
+}
+
+// Requirements returns the requirements for the websocket extension
+func (w *WebSocketExtension) Requirements() []reflect.Type {
+	if w.pubSub == nil {
+		return []reflect.Type{
+			reflect.TypeOf(ValkeyExtension{}),
+			reflect.TypeOf(SessionExtension{}),
+		}
+	}
+	return []reflect.Type{
+		reflect.TypeOf(SessionExtension{}),
+	}
+}
+
+// Broadcast sends the message to all connections in the room, on all instances of the app
+func (w *WebSocketExtension) Broadcast(ctx context.Context, room string, message []byte) error {
+	return w.pubSub.Publish(ctx, w.keyPrefix+"room:"+room, message)
+}
+
+// SendToSession sends the message to all connections of the session, on all instances of the app
+func (w *WebSocketExtension) SendToSession(ctx context.Context, sessionID string, message []byte) error {
+	return w.Broadcast(ctx, sessionChannelPrefix+sessionID, message)
+}
+
+// handle authenticates and upgrades the request, and serves the connection until it is closed
+func (w *WebSocketExtension) handle(c echo.Context) error {
+	sessionID, _ := c.Request().Context().Value(w.sessionExtension.sessionKey).(string)
+	if sessionID == "" {
+		return echo.ErrUnauthorized
+	}
+	if w.authenticator != nil {
+		ok, err := w.authenticator(c)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return echo.ErrUnauthorized
+		}
+	}
+
+	ws, err := w.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// The upgrader already responded with an error
+		w.logger.Debug("Failed to upgrade websocket", slog.Any("err", err))
+		return nil
+	}
+
+	id, err := newWebSocketConnID()
+	if err != nil {
+		_ = ws.Close()
+		return err
+	}
+	conn := &WebSocketConn{
+		ID:        id,
+		SessionID: sessionID,
+		ext:       w,
+		conn:      ws,
+		send:      make(chan []byte, w.sendBufferSize),
+		done:      make(chan struct{}),
+		rooms:     make(map[string]struct{}),
+	}
+
+	w.mu.Lock()
+	if w.ctx.Err() != nil {
+		w.mu.Unlock()
+		conn.closeWith(websocket.CloseGoingAway, "shutting down")
+		return nil
+	}
+	w.conns[conn] = struct{}{}
+	w.mu.Unlock()
+
+	w.logger.Debug("Websocket connected", slog.String("conn", conn.ID))
+	// Every connection is in the room of its session, so SendToSession reaches it
+	conn.Join(sessionChannelPrefix + sessionID)
+
+	go w.writeLoop(conn)
+	if w.onConnect != nil {
+		if err := w.onConnect(conn); err != nil {
+			w.logger.Debug("Websocket rejected", slog.String("conn", conn.ID), slog.Any("err", err))
+			conn.closeWith(websocket.ClosePolicyViolation, err.Error())
+		}
+	}
+	w.readLoop(conn)
+
+	conn.Close()
+	conn.mu.Lock()
+	for room := range conn.rooms {
+		w.leave(room, conn)
+	}
+	conn.rooms = map[string]struct{}{}
+	conn.mu.Unlock()
+
+	w.mu.Lock()
+	delete(w.conns, conn)
+	w.mu.Unlock()
+
+	if w.onDisconnect != nil {
+		w.onDisconnect(conn)
+	}
+	w.logger.Debug("Websocket disconnected", slog.String("conn", conn.ID))
+	return nil
+}
+
+// readLoop reads the messages of the client until the connection is closed
+func (w *WebSocketExtension) readLoop(conn *WebSocketConn) {
+	conn.conn.SetReadLimit(w.maxMessageSize)
+	_ = conn.conn.SetReadDeadline(time.Now().Add(2 * w.pingInterval))
+	conn.conn.SetPongHandler(func(string) error {
+		return conn.conn.SetReadDeadline(time.Now().Add(2 * w.pingInterval))
+	})
+
+	for {
+		_, message, err := conn.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if w.onMessage != nil {
+			w.onMessage(conn, message)
+		}
+	}
+}
+
+// writeLoop writes the queued messages and the pings, it is the only writer of the connection besides the close message
+func (w *WebSocketExtension) writeLoop(conn *WebSocketConn) {
+	ping := time.NewTicker(w.pingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-conn.done:
+			return
+		case message := <-conn.send:
+			_ = conn.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+			err = conn.conn.WriteMessage(websocket.TextMessage, message)
+		case <-ping.C:
+			err = conn.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(w.writeTimeout))
+		}
+		if err != nil {
+			conn.Close()
+			return
+		}
+	}
+}
+
+// join adds the connection to the room, the first connection of a room starts the subscription of this instance
+func (w *WebSocketExtension) join(room string, conn *WebSocketConn) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	r, ok := w.rooms[room]
+	if !ok {
+		ctx, cancel := context.WithCancel(w.ctx)
+		r = &wsRoom{cancel: cancel, conns: make(map[*WebSocketConn]struct{})}
+		w.rooms[room] = r
+		go w.listen(ctx, room)
+	}
+	r.conns[conn] = struct{}{}
+}
+
+// leave removes the connection from the room, the last connection of a room stops the subscription
+func (w *WebSocketExtension) leave(room string, conn *WebSocketConn) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	r, ok := w.rooms[room]
+	if !ok {
+		return
+	}
+	delete(r.conns, conn)
+	if len(r.conns) == 0 {
+		r.cancel()
+		delete(w.rooms, room)
+	}
+}
+
+// listen subscribes to the room until ctx is done, the subscription is retried when it fails
+func (w *WebSocketExtension) listen(ctx context.Context, room string) {
+	for {
+		err := w.pubSub.Subscribe(ctx, w.keyPrefix+"room:"+room, func(message []byte) {
+			w.dispatch(room, message)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		w.logger.Error("Subscription failed, resubscribing", slog.String("room", room), slog.Any("err", err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventsResubscribeDelay):
+		}
+	}
+}
+
+// dispatch sends the message to the connections of the room on this instance
+func (w *WebSocketExtension) dispatch(room string, message []byte) {
+	w.mu.Lock()
+	r, ok := w.rooms[room]
+	if !ok {
+		w.mu.Unlock()
+		return
+	}
+	conns := make([]*WebSocketConn, 0, len(r.conns))
+	for conn := range r.conns {
+		conns = append(conns, conn)
+	}
+	w.mu.Unlock()
+
+	// Send is called without holding the lock, because closing a slow connection makes it leave its rooms
+	for _, conn := range conns {
+		_ = conn.Send(message)
+	}
+}
+
+// closeAll closes all connections and stops all subscriptions
+func (w *WebSocketExtension) closeAll() {
+	w.mu.Lock()
+	w.cancel()
+	conns := make([]*WebSocketConn, 0, len(w.conns))
+	for conn := range w.conns {
+		conns = append(conns, conn)
+	}
+	w.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.closeWith(websocket.CloseGoingAway, "shutting down")
+	}
+}
+
+// newWebSocketConnID returns a random id for a connection
+func newWebSocketConnID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}